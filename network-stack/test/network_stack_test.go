@@ -0,0 +1,204 @@
+package test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	th "terratest-helpers"
+)
+
+// Global test variables
+var (
+	location                     = "CanadaCentral"
+	clientName                   = "client"
+	environment                  = "test"
+	stack                        = "stack"
+	setupTerraformDir            = "terraform"
+	policyDir                    = "../../policies"
+	testSetupDir                 = "terraform/"
+	testSetupTerraformOptionsDir = "setupTerraformOptions/"
+)
+
+type NetworkStackTestData struct {
+	rgName   string
+	vNetCidr string
+	// Subnet CIDRs by subnet key
+	subnets map[string]string
+}
+
+func TestNetworkStack(t *testing.T) {
+	testRootDir := "TestNetworkStack/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_vnet_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_nsg_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_routeTable_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_subnet_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	testData := NetworkStackTestData{
+		rgName:   fmt.Sprintf("rg-stack-unit-test-%s", nameSuffix),
+		vNetCidr: "10.15.0.0/16",
+		subnets: map[string]string{
+			"app":  "10.15.0.0/24",
+			"data": "10.15.1.0/24",
+		},
+	}
+
+	NetworkStack(t, testRootDir, nameSuffix, testData)
+}
+
+// Returns the modules of the stack, in deployment order. The subnets are deployed in the virtual network of the vnet
+// module, and associated with the network security group and route table of the nsg and route-table modules.
+func NetworkStackModules(nameSuffix string, testData NetworkStackTestData) []th.StackModule {
+	naming := func(vars map[string]interface{}) map[string]interface{} {
+		vars["name_suffix"] = nameSuffix
+		vars["client_name"] = clientName
+		vars["environment"] = environment
+		vars["stack"] = stack
+		return vars
+	}
+
+	subnets := map[string]interface{}{}
+	for key, cidr := range testData.subnets {
+		subnets[key] = map[string]interface{}{
+			"cidr_list":                   []string{cidr},
+			"network_security_group_name": th.StackOutput{Module: "nsg", Output: "nsg_name"},
+			"route_table_name":            th.StackOutput{Module: "routeTable", Output: "route_table_name"},
+		}
+	}
+
+	return []th.StackModule{
+		{
+			Name:         "vnet",
+			TerraformDir: "../../terraform-azurerm-vnet",
+			Vars: naming(map[string]interface{}{
+				"location":            location,
+				"resource_group_name": testData.rgName,
+				"vnet_cidr":           []string{testData.vNetCidr},
+			}),
+			PolicyDir: policyDir,
+			// The test virtual network has no DDoS protection plan
+			PolicyOptions: th.PolicyOptions{Exceptions: map[string][]string{"vnet_ddos": {"azurerm_virtual_network.vnet"}}},
+		},
+		{
+			Name:         "nsg",
+			TerraformDir: "../../terraform-azurerm-nsg",
+			Vars: naming(map[string]interface{}{
+				"location":            location,
+				"resource_group_name": testData.rgName,
+			}),
+			PolicyDir: policyDir,
+		},
+		{
+			Name:         "routeTable",
+			TerraformDir: "../../terraform-azurerm-route-table",
+			Vars: naming(map[string]interface{}{
+				"location":            location,
+				"resource_group_name": testData.rgName,
+			}),
+			PolicyDir: policyDir,
+		},
+		{
+			Name:         "subnet",
+			TerraformDir: "../../terraform-azurerm-subnet",
+			Vars: naming(map[string]interface{}{
+				"vnet_resource_group_name": testData.rgName,
+				"vnet_name":                th.StackOutput{Module: "vnet", Output: "vnet_name"},
+				"subnets":                  subnets,
+			}),
+			PolicyDir: policyDir,
+		},
+	}
+}
+
+func NetworkStack(t *testing.T, testRootDir string, nameSuffix string, testData NetworkStackTestData) {
+	modules := NetworkStackModules(nameSuffix, testData)
+
+	// At the end of the test, clean up resources.
+	defer th.RunTestStage(t, "teardown_"+testRootDir, func() {
+		TearDown(t, testRootDir, modules)
+	})
+
+	// Lint the modules of the stack copied into the testRootDir before deploying anything (the setup stage removes the
+	// copies, and the deploy stage copies the modules again)
+	th.RunTestStage(t, "lint_"+testRootDir, func() {
+		th.LintStack(t, testRootDir, modules)
+	})
+
+	th.RunTestStage(t, "setup_"+testRootDir, func() {
+		// If state files exist, clean up resources
+		TearDown(t, testRootDir, modules)
+		th.CopyTerraformFolder(t, setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
+		ts.SaveString(t, testRootDir, "nameSuffix", nameSuffix)
+
+		setupTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir), map[string]interface{}{
+			"config": map[string]interface{}{
+				"location":            location,
+				"resource_group_name": testData.rgName,
+			},
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir, setupTerraformOptions)
+		th.InitAndApply(t, setupTerraformOptions)
+	})
+
+	// Deploy the modules in order, each one getting its inputs from the outputs of the modules deployed before it
+	th.RunTestStage(t, "deploy_"+testRootDir, func() {
+		th.DeployStack(t, testRootDir, modules)
+	})
+
+	th.RunTestStage(t, "validate_"+testRootDir, func() {
+		vnetID := terraform.Output(t, th.LoadStackTerraformOptions(t, testRootDir, "vnet"), "vnet_id")
+		nsgID := terraform.Output(t, th.LoadStackTerraformOptions(t, testRootDir, "nsg"), "nsg_id")
+		routeTableID := terraform.Output(t, th.LoadStackTerraformOptions(t, testRootDir, "routeTable"), "route_table_id")
+		subnetTerraformOptions := th.LoadStackTerraformOptions(t, testRootDir, "subnet")
+		subnetIDs := terraform.OutputMap(t, subnetTerraformOptions, "subnet_ids")
+		subnetNames := terraform.OutputMap(t, subnetTerraformOptions, "subnet_names")
+
+		// Ensure that the virtual network holds exactly the subnets of the subnet module
+		expectedSubnetNames := []string{}
+		for key := range testData.subnets {
+			expectedSubnetNames = append(expectedSubnetNames, subnetNames[key])
+		}
+		th.AssertExpectation(t, th.GetAzureResource(t, vnetID), th.VirtualNetworkExpectation{
+			AddressSpace: []string{testData.vNetCidr},
+			Subnets:      expectedSubnetNames,
+		})
+
+		// Ensure that each subnet lies in the virtual network's address space, and is associated with the network
+		// security group and route table of the stack
+		_, vNetNetwork, err := net.ParseCIDR(testData.vNetCidr)
+		th.Assert(t).NoError(err)
+		for key, cidr := range testData.subnets {
+			subnetIP, _, err := net.ParseCIDR(cidr)
+			th.Assert(t).NoError(err)
+			th.Assert(t).True(vNetNetwork.Contains(subnetIP), "Subnet %s (%s) is not in %s", key, cidr, testData.vNetCidr)
+			th.Assert(t).True(strings.HasPrefix(strings.ToLower(subnetIDs[key]), strings.ToLower(vnetID+"/subnets/")), "Subnet %s is not in %s", key, vnetID)
+
+			th.AssertExpectation(t, th.GetAzureResource(t, subnetIDs[key]), th.SubnetExpectation{
+				Name:                   subnetNames[key],
+				AddressPrefixes:        []string{cidr},
+				NetworkSecurityGroupID: nsgID,
+				RouteTableID:           routeTableID,
+			}, "Subnet %s", key)
+		}
+	})
+}
+
+// Destroys the modules of the stack in reverse order, then the setup resources, and removes the testRootDir
+func TearDown(t *testing.T, testRootDir string, modules []th.StackModule) {
+	th.TearDownStack(t, testRootDir, modules, testSetupTerraformOptionsDir)
+}
@@ -0,0 +1,45 @@
+# Every subnet must be associated with a network security group, unless its address (or an address prefix, eg.
+# azurerm_subnet.subnet for every instance) is listed in input.exceptions.subnet_nsg
+package terraform.subnet_nsg
+
+deny[msg] {
+	change := input.resource_changes[_]
+	change.type == "azurerm_subnet"
+	not deleted(change)
+	not nsg_associated(change)
+	not excepted(change.address)
+	msg := sprintf("%s must be associated with a network security group or have an exception", [change.address])
+}
+
+deleted(change) {
+	change.change.actions == ["delete"]
+}
+
+# The association is in the same module as the subnet and has the same instance key (a subnet without a key matches
+# an association created with count = 1)
+nsg_associated(subnet) {
+	association := input.resource_changes[_]
+	association.type == "azurerm_subnet_network_security_group_association"
+	not deleted(association)
+	object.get(association, "module_address", "") == object.get(subnet, "module_address", "")
+	same_instance(object.get(subnet, "index", null), object.get(association, "index", null))
+}
+
+same_instance(subnet_index, association_index) {
+	subnet_index == association_index
+}
+
+same_instance(subnet_index, association_index) {
+	subnet_index == null
+	association_index == 0
+}
+
+excepted(address) {
+	exception := input.exceptions.subnet_nsg[_]
+	address == exception
+}
+
+excepted(address) {
+	exception := input.exceptions.subnet_nsg[_]
+	startswith(address, sprintf("%s[", [exception]))
+}
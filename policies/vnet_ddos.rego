@@ -0,0 +1,31 @@
+# Every virtual network must be protected by a DDoS protection plan, unless its address (or an address prefix, eg.
+# azurerm_virtual_network.vnet for every instance) is listed in input.exceptions.vnet_ddos
+package terraform.vnet_ddos
+
+deny[msg] {
+	change := input.resource_changes[_]
+	change.type == "azurerm_virtual_network"
+	not deleted(change)
+	not ddos_enabled(change.change.after)
+	not excepted(change.address)
+	msg := sprintf("%s must have DDoS protection enabled", [change.address])
+}
+
+deleted(change) {
+	change.change.actions == ["delete"]
+}
+
+ddos_enabled(after) {
+	plan := after.ddos_protection_plan[_]
+	plan.enable == true
+}
+
+excepted(address) {
+	exception := input.exceptions.vnet_ddos[_]
+	address == exception
+}
+
+excepted(address) {
+	exception := input.exceptions.vnet_ddos[_]
+	startswith(address, sprintf("%s[", [exception]))
+}
@@ -0,0 +1,17 @@
+locals {
+  # Naming locals/constants
+  name_prefix = lower(var.name_prefix)
+  name_suffix = lower(var.name_suffix)
+  nsg_slug    = "nsg"
+  nsg_name    = coalesce(var.custom_nsg_name, azurecaf_name.nsg.result)
+}
+
+resource "azurecaf_name" "nsg" {
+  name          = var.stack
+  resource_type = "azurerm_network_security_group"
+  prefixes      = var.name_prefix == "" ? null : [local.name_prefix]
+  suffixes      = compact([var.client_name, var.environment, local.name_suffix, var.use_caf_naming ? "" : local.nsg_slug])
+  use_slug      = var.use_caf_naming
+  clean_input   = true
+  separator     = "-"
+}
@@ -0,0 +1,30 @@
+resource "azurerm_network_security_group" "nsg" {
+  name                = local.nsg_name
+  location            = var.location
+  resource_group_name = var.resource_group_name
+  tags                = var.tags
+}
+
+# Rules are separate resources keyed by name, so adding or removing a rule does not replace the others
+resource "azurerm_network_security_rule" "rule" {
+  for_each = { for rule in var.security_rules : rule.name => rule }
+
+  name                        = each.key
+  resource_group_name         = var.resource_group_name
+  network_security_group_name = azurerm_network_security_group.nsg.name
+  description                 = each.value.description
+  priority                    = each.value.priority
+  direction                   = each.value.direction
+  access                      = each.value.access
+  protocol                    = each.value.protocol
+
+  # A single value (eg. * or a service tag) can only be set on the singular attribute
+  source_port_range            = length(each.value.source_port_ranges) == 1 ? each.value.source_port_ranges[0] : null
+  source_port_ranges           = length(each.value.source_port_ranges) == 1 ? null : each.value.source_port_ranges
+  destination_port_range       = length(each.value.destination_port_ranges) == 1 ? each.value.destination_port_ranges[0] : null
+  destination_port_ranges      = length(each.value.destination_port_ranges) == 1 ? null : each.value.destination_port_ranges
+  source_address_prefix        = length(each.value.source_address_prefixes) == 1 ? each.value.source_address_prefixes[0] : null
+  source_address_prefixes      = length(each.value.source_address_prefixes) == 1 ? null : each.value.source_address_prefixes
+  destination_address_prefix   = length(each.value.destination_address_prefixes) == 1 ? each.value.destination_address_prefixes[0] : null
+  destination_address_prefixes = length(each.value.destination_address_prefixes) == 1 ? null : each.value.destination_address_prefixes
+}
@@ -0,0 +1,14 @@
+output "nsg_id" {
+  description = "Id of the created network security group"
+  value       = azurerm_network_security_group.nsg.id
+}
+
+output "nsg_name" {
+  description = "Name of the created network security group"
+  value       = azurerm_network_security_group.nsg.name
+}
+
+output "security_rule_ids" {
+  description = "Ids of the created security rules, by rule name"
+  value       = { for name, rule in azurerm_network_security_rule.rule : name => rule.id }
+}
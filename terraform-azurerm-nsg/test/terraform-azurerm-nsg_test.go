@@ -0,0 +1,253 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	th "terratest-helpers"
+)
+
+// Global test variables
+var (
+	location                      = "CanadaCentral"
+	clientName                    = "client"
+	environment                   = "test"
+	stack                         = "stack"
+	setupTerraformDir             = "terraform"
+	moduleTerraformDir            = "../"
+	policyDir                     = "../../policies"
+	testModuleDir                 = "module/"
+	testSetupDir                  = "terraform/"
+	testModuleTerraformOptionsDir = "nsgTerraformOptions/"
+	testSetupTerraformOptionsDir  = "setupTerraformOptions/"
+)
+
+type NetworkSecurityGroupTestData struct {
+	nsgRgName       string
+	expectedNsgName string
+	securityRules   []SecurityRule
+}
+
+// A security rule of the security_rules variable. The ranges and prefixes are left to the module default (*) when nil.
+type SecurityRule struct {
+	name                       string
+	priority                   int
+	direction                  string
+	access                     string
+	protocol                   string
+	sourcePortRanges           []string
+	destinationPortRanges      []string
+	sourceAddressPrefixes      []string
+	destinationAddressPrefixes []string
+}
+
+func TestNetworkSecurityGroupWithRules(t *testing.T) {
+	testRootDir := "TestNetworkSecurityGroupWithRules/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	testData := NetworkSecurityGroupTestData{
+		nsgRgName:       fmt.Sprintf("rg-nsg-unit-test-%s", nameSuffix),
+		expectedNsgName: fmt.Sprintf("nsg-stack-client-test-%s", nameSuffix),
+		securityRules: []SecurityRule{
+			{
+				name:                  "allow-https-inbound",
+				priority:              100,
+				direction:             "Inbound",
+				access:                "Allow",
+				protocol:              "Tcp",
+				destinationPortRanges: []string{"443"},
+				sourceAddressPrefixes: []string{"VirtualNetwork"},
+			},
+			{
+				name:                       "allow-app-inbound",
+				priority:                   110,
+				direction:                  "Inbound",
+				access:                     "Allow",
+				protocol:                   "Tcp",
+				destinationPortRanges:      []string{"8080", "8443-8445"},
+				sourceAddressPrefixes:      []string{"10.1.0.0/24", "10.1.1.0/24"},
+				destinationAddressPrefixes: []string{"10.0.0.0/24"},
+			},
+			{
+				name:                       "deny-internet-outbound",
+				priority:                   4096,
+				direction:                  "Outbound",
+				access:                     "Deny",
+				protocol:                   "*",
+				destinationAddressPrefixes: []string{"Internet"},
+			},
+		},
+	}
+
+	NetworkSecurityGroup(t, testRootDir, nameSuffix, testData)
+}
+
+func TestNetworkSecurityGroupWithoutRules(t *testing.T) {
+	testRootDir := "TestNetworkSecurityGroupWithoutRules/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	// Only the default security rules of Azure apply
+	testData := NetworkSecurityGroupTestData{
+		nsgRgName:       fmt.Sprintf("rg-nsg-unit-test-%s", nameSuffix),
+		expectedNsgName: fmt.Sprintf("nsg-stack-client-test-%s", nameSuffix),
+		securityRules:   []SecurityRule{},
+	}
+
+	NetworkSecurityGroup(t, testRootDir, nameSuffix, testData)
+}
+
+func NetworkSecurityGroup(t *testing.T, testRootDir string, nameSuffix string, testData NetworkSecurityGroupTestData) {
+	// At the end of the test, clean up resources.
+	defer th.RunTestStage(t, "teardown_"+testRootDir, func() {
+		TearDown(t, testRootDir)
+	})
+
+	// Lint the module copied into the testRootDir before deploying anything (the setup stage removes the copy, and the
+	// deploy stage copies the module again)
+	th.RunTestStage(t, "lint_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+		th.LintModule(t, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+	})
+
+	th.RunTestStage(t, "setup_"+testRootDir, func() {
+		// If state files exist, clean up resources
+		TearDown(t, testRootDir)
+		th.CopyTerraformFolder(t, setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
+		ts.SaveString(t, testRootDir, "nameSuffix", nameSuffix)
+
+		setupTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir), map[string]interface{}{
+			"config": map[string]interface{}{
+				"location":            location,
+				"resource_group_name": testData.nsgRgName,
+			},
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir, setupTerraformOptions)
+		th.InitAndApply(t, setupTerraformOptions)
+	})
+
+	th.RunTestStage(t, "deploy_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+
+		nsgTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir), map[string]interface{}{
+			"location":            location,
+			"resource_group_name": testData.nsgRgName,
+			"name_suffix":         nameSuffix,
+			"client_name":         clientName,
+			"environment":         environment,
+			"stack":               stack,
+			"security_rules":      SecurityRulesVariable(testData.securityRules),
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir, nsgTerraformOptions)
+
+		// Check the module plan against the security policies before applying it
+		th.RunTestStage(t, "policy_"+testRootDir, func() {
+			th.CheckPlanPolicies(t, nsgTerraformOptions, policyDir, th.PolicyOptions{})
+		})
+		th.InitAndApply(t, nsgTerraformOptions)
+	})
+
+	th.RunTestStage(t, "validate_"+testRootDir, func() {
+		nsgTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir)
+		deployedNsg := th.GetAzureResource(t, terraform.Output(t, nsgTerraformOptions, "nsg_id"))
+
+		// Ensure that the network security group has exactly the security rules of the test data, with the expected
+		// priority, direction, access, protocol, port ranges and address prefixes. Missing and extra rules are reported
+		// along with the mismatching fields of each rule.
+		expectedRules := []th.SecurityRuleExpectation{}
+		for _, rule := range testData.securityRules {
+			expectedRules = append(expectedRules, SecurityRuleExpectation(rule))
+		}
+		th.AssertExpectation(t, deployedNsg, th.NetworkSecurityGroupExpectation{
+			Name:          testData.expectedNsgName,
+			Location:      location,
+			SecurityRules: expectedRules,
+		})
+
+		// Ensure that the module outputs the ID of every rule
+		securityRuleIDs := terraform.OutputMap(t, nsgTerraformOptions, "security_rule_ids")
+		th.Assert(t).Len(securityRuleIDs, len(testData.securityRules))
+		for _, rule := range testData.securityRules {
+			th.Assert(t).Contains(securityRuleIDs, rule.name)
+		}
+	})
+}
+
+// Returns the security_rules variable of the module
+func SecurityRulesVariable(rules []SecurityRule) []map[string]interface{} {
+	variable := []map[string]interface{}{}
+	for _, rule := range rules {
+		value := map[string]interface{}{
+			"name":      rule.name,
+			"priority":  rule.priority,
+			"direction": rule.direction,
+			"access":    rule.access,
+			"protocol":  rule.protocol,
+		}
+		if rule.sourcePortRanges != nil {
+			value["source_port_ranges"] = rule.sourcePortRanges
+		}
+		if rule.destinationPortRanges != nil {
+			value["destination_port_ranges"] = rule.destinationPortRanges
+		}
+		if rule.sourceAddressPrefixes != nil {
+			value["source_address_prefixes"] = rule.sourceAddressPrefixes
+		}
+		if rule.destinationAddressPrefixes != nil {
+			value["destination_address_prefixes"] = rule.destinationAddressPrefixes
+		}
+		variable = append(variable, value)
+	}
+	return variable
+}
+
+// Returns the expected deployed rule, with the module defaults (*) for the ranges and prefixes left unset
+func SecurityRuleExpectation(rule SecurityRule) th.SecurityRuleExpectation {
+	orAny := func(values []string) []string {
+		if values == nil {
+			return []string{"*"}
+		}
+		return values
+	}
+	return th.SecurityRuleExpectation{
+		Name:                       rule.name,
+		Priority:                   rule.priority,
+		Direction:                  rule.direction,
+		Access:                     rule.access,
+		Protocol:                   rule.protocol,
+		SourcePortRanges:           orAny(rule.sourcePortRanges),
+		DestinationPortRanges:      orAny(rule.destinationPortRanges),
+		SourceAddressPrefixes:      orAny(rule.sourceAddressPrefixes),
+		DestinationAddressPrefixes: orAny(rule.destinationAddressPrefixes),
+	}
+}
+
+// Destroys the network security group and setup resources and removes the testRootDir
+func TearDown(t *testing.T, testRootDir string) {
+	th.TearDown(t, testRootDir, testModuleTerraformOptionsDir, testSetupTerraformOptionsDir)
+}
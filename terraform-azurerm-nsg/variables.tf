@@ -0,0 +1,60 @@
+# General configs
+
+variable "location" {
+  type        = string
+  description = "Azure region for resource deployment"
+}
+
+variable "resource_group_name" {
+  type        = string
+  description = "Resource group that the network security group lies in"
+}
+
+variable "tags" {
+  type        = map(string)
+  description = "Tags to add to the network security group"
+  default     = {}
+}
+
+# Security rules
+
+variable "security_rules" {
+  type = list(object({
+    name                         = string
+    priority                     = number
+    direction                    = string
+    access                       = string
+    protocol                     = string
+    source_port_ranges           = optional(list(string), ["*"])
+    destination_port_ranges      = optional(list(string), ["*"])
+    source_address_prefixes      = optional(list(string), ["*"])
+    destination_address_prefixes = optional(list(string), ["*"])
+    description                  = optional(string)
+  }))
+  description = <<EOD
+Security rules of the network security group
+list(object({
+  name                         = string       # Unique name of the rule
+  priority                     = number       # Between 100 and 4096, unique per direction
+  direction                    = string       # Inbound or Outbound
+  access                       = string       # Allow or Deny
+  protocol                     = string       # Tcp, Udp, Icmp, Esp, Ah or *
+  source_port_ranges           = list(string) # Ports or port ranges (eg. 443 or 8000-8080). Default is ["*"].
+  destination_port_ranges      = list(string) # Ports or port ranges (eg. 443 or 8000-8080). Default is ["*"].
+  source_address_prefixes      = list(string) # CIDRs, IP addresses or a single service tag (eg. VirtualNetwork). Default is ["*"].
+  destination_address_prefixes = list(string) # CIDRs, IP addresses or a single service tag (eg. VirtualNetwork). Default is ["*"].
+  description                  = string       # Optional description of the rule
+}))
+EOD
+  default = []
+
+  validation {
+    condition     = length(distinct([for rule in var.security_rules : rule.name])) == length(var.security_rules)
+    error_message = "The security rule names must be unique."
+  }
+
+  validation {
+    condition     = alltrue([for rule in var.security_rules : contains(["Inbound", "Outbound"], rule.direction) && contains(["Allow", "Deny"], rule.access)])
+    error_message = "The security rule directions must be Inbound or Outbound, and their accesses Allow or Deny."
+  }
+}
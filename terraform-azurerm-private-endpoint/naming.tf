@@ -0,0 +1,29 @@
+locals {
+  # Naming locals/constants
+  name_prefix                     = lower(var.name_prefix)
+  name_suffix                     = lower(var.name_suffix)
+  private_endpoint_slug           = "pe"
+  private_service_connection_slug = "psc"
+  private_endpoint_name           = coalesce(var.custom_private_endpoint_name, azurecaf_name.private_endpoint.result)
+  private_service_connection_name = coalesce(var.custom_private_service_connection_name, azurecaf_name.private_service_connection.result)
+}
+
+resource "azurecaf_name" "private_endpoint" {
+  name          = var.stack
+  resource_type = "azurerm_private_endpoint"
+  prefixes      = var.name_prefix == "" ? null : [local.name_prefix]
+  suffixes      = compact([var.client_name, var.environment, local.name_suffix, var.use_caf_naming ? "" : local.private_endpoint_slug])
+  use_slug      = var.use_caf_naming
+  clean_input   = true
+  separator     = "-"
+}
+
+resource "azurecaf_name" "private_service_connection" {
+  name          = var.stack
+  resource_type = "azurerm_private_service_connection"
+  prefixes      = var.name_prefix == "" ? null : [local.name_prefix]
+  suffixes      = compact([var.client_name, var.environment, local.name_suffix, var.use_caf_naming ? "" : local.private_service_connection_slug])
+  use_slug      = var.use_caf_naming
+  clean_input   = true
+  separator     = "-"
+}
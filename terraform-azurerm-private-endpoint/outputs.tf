@@ -0,0 +1,24 @@
+output "private_endpoint_id" {
+  description = "Id of the created private endpoint"
+  value       = azurerm_private_endpoint.private_endpoint.id
+}
+
+output "private_endpoint_name" {
+  description = "Name of the created private endpoint"
+  value       = azurerm_private_endpoint.private_endpoint.name
+}
+
+output "private_service_connection_name" {
+  description = "Name of the connection of the private endpoint to the private link resource"
+  value       = azurerm_private_endpoint.private_endpoint.private_service_connection[0].name
+}
+
+output "private_ip_address" {
+  description = "Private IP address of the private endpoint in its subnet"
+  value       = azurerm_private_endpoint.private_endpoint.private_service_connection[0].private_ip_address
+}
+
+output "network_interface_id" {
+  description = "Id of the network interface of the private endpoint"
+  value       = azurerm_private_endpoint.private_endpoint.network_interface[0].id
+}
@@ -0,0 +1,23 @@
+resource "azurerm_private_endpoint" "private_endpoint" {
+  name                = local.private_endpoint_name
+  location            = var.location
+  resource_group_name = var.resource_group_name
+  subnet_id           = var.subnet_id
+  tags                = var.tags
+
+  # Connections to resources of the same tenant the deployment identity has access to are approved automatically
+  private_service_connection {
+    name                           = local.private_service_connection_name
+    private_connection_resource_id = var.private_connection_resource_id
+    subresource_names              = var.subresource_names
+    is_manual_connection           = false
+  }
+
+  dynamic "private_dns_zone_group" {
+    for_each = length(var.private_dns_zone_ids) == 0 ? [] : ["default"]
+    content {
+      name                 = private_dns_zone_group.value
+      private_dns_zone_ids = var.private_dns_zone_ids
+    }
+  }
+}
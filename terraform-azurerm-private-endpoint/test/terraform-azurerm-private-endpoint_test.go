@@ -0,0 +1,236 @@
+package test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	th "terratest-helpers"
+)
+
+// Global test variables
+var (
+	location                      = "CanadaCentral"
+	clientName                    = "client"
+	environment                   = "test"
+	stack                         = "stack"
+	setupTerraformDir             = "terraform"
+	moduleTerraformDir            = "../"
+	subnetTerraformDir            = "../../terraform-azurerm-subnet"
+	policyDir                     = "../../policies"
+	testModuleDir                 = "module/"
+	testSetupDir                  = "terraform/"
+	testSubnetDir                 = "subnet/"
+	testModuleTerraformOptionsDir = "privateEndpointTerraformOptions/"
+	testSubnetTerraformOptionsDir = "subnetTerraformOptions/"
+	testSetupTerraformOptionsDir  = "setupTerraformOptions/"
+	// Key of the subnet in the subnets variable of the subnet module
+	subnetKey = "endpoints"
+)
+
+type PrivateEndpointTestData struct {
+	vNetRgName                  string
+	vNetCidr                    string
+	vNetName                    string
+	subnetCidr                  string
+	privateEndpointEnabled      bool
+	storageAccountName          string
+	subresourceNames            []string
+	expectedPrivateEndpointName string
+	expectedConnectionName      string
+}
+
+func TestPrivateEndpointWithNetworkPoliciesEnabled(t *testing.T) {
+	testRootDir := "TestPrivateEndpointWithNetworkPoliciesEnabled/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	// Network security groups and route tables of the subnet apply to the private endpoint
+	testData := PrivateEndpointTestData{
+		vNetRgName:                  fmt.Sprintf("rg-pe-unit-test-%s", nameSuffix),
+		vNetCidr:                    "10.13.0.0/16",
+		vNetName:                    fmt.Sprintf("vnet-pe-unit-test-%s", nameSuffix),
+		subnetCidr:                  "10.13.0.0/24",
+		privateEndpointEnabled:      true,
+		storageAccountName:          fmt.Sprintf("stpeunittest%s", nameSuffix),
+		subresourceNames:            []string{"blob"},
+		expectedPrivateEndpointName: fmt.Sprintf("pe-stack-client-test-%s", nameSuffix),
+		expectedConnectionName:      fmt.Sprintf("psc-stack-client-test-%s", nameSuffix),
+	}
+
+	PrivateEndpoint(t, testRootDir, nameSuffix, testData)
+}
+
+func TestPrivateEndpointWithNetworkPoliciesDisabled(t *testing.T) {
+	testRootDir := "TestPrivateEndpointWithNetworkPoliciesDisabled/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	// Network security groups and route tables of the subnet are bypassed by the private endpoint
+	testData := PrivateEndpointTestData{
+		vNetRgName:                  fmt.Sprintf("rg-pe-unit-test-%s", nameSuffix),
+		vNetCidr:                    "10.14.0.0/16",
+		vNetName:                    fmt.Sprintf("vnet-pe-unit-test-%s", nameSuffix),
+		subnetCidr:                  "10.14.0.0/24",
+		privateEndpointEnabled:      false,
+		storageAccountName:          fmt.Sprintf("stpeunittest%s", nameSuffix),
+		subresourceNames:            []string{"blob"},
+		expectedPrivateEndpointName: fmt.Sprintf("pe-stack-client-test-%s", nameSuffix),
+		expectedConnectionName:      fmt.Sprintf("psc-stack-client-test-%s", nameSuffix),
+	}
+
+	PrivateEndpoint(t, testRootDir, nameSuffix, testData)
+}
+
+func PrivateEndpoint(t *testing.T, testRootDir string, nameSuffix string, testData PrivateEndpointTestData) {
+	// At the end of the test, clean up resources.
+	defer th.RunTestStage(t, "teardown_"+testRootDir, func() {
+		TearDown(t, testRootDir)
+	})
+
+	// Lint the module copied into the testRootDir before deploying anything (the setup stage removes the copy, and the
+	// deploy stage copies the module again)
+	th.RunTestStage(t, "lint_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+		th.LintModule(t, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+	})
+
+	th.RunTestStage(t, "setup_"+testRootDir, func() {
+		// If state files exist, clean up resources
+		TearDown(t, testRootDir)
+		th.CopyTerraformFolder(t, setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
+		ts.SaveString(t, testRootDir, "nameSuffix", nameSuffix)
+
+		setupTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir), map[string]interface{}{
+			"config": map[string]interface{}{
+				"location":             location,
+				"resource_group_name":  testData.vNetRgName,
+				"vnet_name":            testData.vNetName,
+				"address_space":        []string{testData.vNetCidr},
+				"storage_account_name": testData.storageAccountName,
+			},
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir, setupTerraformOptions)
+		th.InitAndApply(t, setupTerraformOptions)
+	})
+
+	th.RunTestStage(t, "deploy_"+testRootDir, func() {
+		// Deploy the subnet the private endpoint is placed in with the subnet module
+		th.CopyTerraformFolder(t, subnetTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSubnetDir))
+
+		subnetTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testSubnetDir), map[string]interface{}{
+			"vnet_resource_group_name": testData.vNetRgName,
+			"vnet_name":                testData.vNetName,
+			"name_suffix":              nameSuffix,
+			"client_name":              clientName,
+			"environment":              environment,
+			"stack":                    stack,
+			"subnets": map[string]interface{}{
+				subnetKey: map[string]interface{}{
+					"cidr_list":                []string{testData.subnetCidr},
+					"private_endpoint_enabled": testData.privateEndpointEnabled,
+				},
+			},
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testSubnetTerraformOptionsDir, subnetTerraformOptions)
+		th.InitAndApply(t, subnetTerraformOptions)
+
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+
+		setupTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir)
+		privateEndpointTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir), map[string]interface{}{
+			"location":                       location,
+			"resource_group_name":            testData.vNetRgName,
+			"name_suffix":                    nameSuffix,
+			"client_name":                    clientName,
+			"environment":                    environment,
+			"stack":                          stack,
+			"subnet_id":                      terraform.OutputMap(t, subnetTerraformOptions, "subnet_ids")[subnetKey],
+			"private_connection_resource_id": terraform.Output(t, setupTerraformOptions, "storage_account_id"),
+			"subresource_names":              testData.subresourceNames,
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir, privateEndpointTerraformOptions)
+
+		// Check the module plan against the security policies before applying it
+		th.RunTestStage(t, "policy_"+testRootDir, func() {
+			th.CheckPlanPolicies(t, privateEndpointTerraformOptions, policyDir, th.PolicyOptions{})
+		})
+		th.InitAndApply(t, privateEndpointTerraformOptions)
+	})
+
+	th.RunTestStage(t, "validate_"+testRootDir, func() {
+		setupTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir)
+		subnetTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testSubnetTerraformOptionsDir)
+		privateEndpointTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir)
+		subnetID := terraform.OutputMap(t, subnetTerraformOptions, "subnet_ids")[subnetKey]
+		privateEndpointID := terraform.Output(t, privateEndpointTerraformOptions, "private_endpoint_id")
+
+		// Ensure that the private endpoint is placed in the subnet, and that its connection to the storage account was
+		// approved (the connection can lag behind the end of terraform apply)
+		th.EventuallyProperty(t, privateEndpointID, "properties.privateLinkServiceConnections[0].properties.privateLinkServiceConnectionState.status", "Approved")
+		deployedPrivateEndpoint := th.GetAzureResource(t, privateEndpointID)
+		th.AssertExpectation(t, deployedPrivateEndpoint, th.PrivateEndpointExpectation{
+			Name:     testData.expectedPrivateEndpointName,
+			Location: location,
+			SubnetID: subnetID,
+			Connections: []th.PrivateLinkServiceConnectionExpectation{{
+				Name:                 testData.expectedConnectionName,
+				PrivateLinkServiceID: terraform.Output(t, setupTerraformOptions, "storage_account_id"),
+				GroupIDs:             testData.subresourceNames,
+				Status:               "Approved",
+			}},
+		})
+
+		// Ensure that the private endpoint got its IP address from the subnet
+		_, subnetNetwork, err := net.ParseCIDR(testData.subnetCidr)
+		th.Assert(t).NoError(err)
+		privateIPAddress := terraform.Output(t, privateEndpointTerraformOptions, "private_ip_address")
+		th.Assert(t).True(subnetNetwork.Contains(net.ParseIP(privateIPAddress)), "Private IP address %s is not in %s", privateIPAddress, testData.subnetCidr)
+
+		// Ensure that the subnet reports the private endpoint, whether its network policies are enabled or not
+		deployedSubnet := th.GetAzureResource(t, subnetID)
+		th.AssertExpectation(t, deployedSubnet, th.SubnetExpectation{
+			PrivateEndpointNetworkPolicies: NetworkPolicies(testData.privateEndpointEnabled),
+			PrivateEndpointIDs:             []string{privateEndpointID},
+		})
+	})
+}
+
+// Returns the network policies state reported by Azure for the private_endpoint_enabled value of the subnet module
+func NetworkPolicies(enabled bool) string {
+	if enabled {
+		return "Enabled"
+	}
+	return "Disabled"
+}
+
+// Destroys the private endpoint, the subnet and the setup resources (in that order, as the private endpoint depends
+// on the subnet and storage account) and removes the testRootDir
+func TearDown(t *testing.T, testRootDir string) {
+	th.TearDown(t, testRootDir, testModuleTerraformOptionsDir, testSubnetTerraformOptionsDir, testSetupTerraformOptionsDir)
+}
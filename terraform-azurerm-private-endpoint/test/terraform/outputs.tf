@@ -0,0 +1,3 @@
+output "storage_account_id" {
+  value = azurerm_storage_account.storage.id
+}
@@ -0,0 +1,28 @@
+terraform {
+  backend "local" {}
+}
+
+provider "azurerm" {
+  features {}
+}
+
+resource "azurerm_resource_group" "rg" {
+  name     = lookup(var.config, "resource_group_name")
+  location = lookup(var.config, "location")
+}
+
+resource "azurerm_virtual_network" "vnet" {
+  name                = lookup(var.config, "vnet_name")
+  location            = lookup(var.config, "location")
+  address_space       = lookup(var.config, "address_space")
+  resource_group_name = azurerm_resource_group.rg.name
+}
+
+# Private link resource the private endpoint connects to
+resource "azurerm_storage_account" "storage" {
+  name                     = lookup(var.config, "storage_account_name")
+  location                 = lookup(var.config, "location")
+  resource_group_name      = azurerm_resource_group.rg.name
+  account_tier             = "Standard"
+  account_replication_type = "LRS"
+}
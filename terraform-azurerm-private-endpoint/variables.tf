@@ -0,0 +1,40 @@
+# General configs
+
+variable "location" {
+  type        = string
+  description = "Azure region for resource deployment"
+}
+
+variable "resource_group_name" {
+  type        = string
+  description = "Resource group that the private endpoint lies in"
+}
+
+variable "tags" {
+  type        = map(string)
+  description = "Tags to add to the private endpoint"
+  default     = {}
+}
+
+# Private endpoint configs
+
+variable "subnet_id" {
+  type        = string
+  description = "Id of the subnet the private endpoint is placed in (eg. the subnet_ids output of terraform-azurerm-subnet)"
+}
+
+variable "private_connection_resource_id" {
+  type        = string
+  description = "Id of the private link resource the private endpoint connects to (eg. a storage account)"
+}
+
+variable "subresource_names" {
+  type        = list(string)
+  description = "Subresources of the private link resource the private endpoint connects to (eg. [\"blob\"] for a storage account)"
+}
+
+variable "private_dns_zone_ids" {
+  type        = list(string)
+  description = "Ids of the private DNS zones the private endpoint registers its IP address in (eg. privatelink.blob.core.windows.net). No zone group is created if empty."
+  default     = []
+}
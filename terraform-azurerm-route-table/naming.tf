@@ -0,0 +1,17 @@
+locals {
+  # Naming locals/constants
+  name_prefix      = lower(var.name_prefix)
+  name_suffix      = lower(var.name_suffix)
+  route_table_slug = "route"
+  route_table_name = coalesce(var.custom_route_table_name, azurecaf_name.route_table.result)
+}
+
+resource "azurecaf_name" "route_table" {
+  name          = var.stack
+  resource_type = "azurerm_route_table"
+  prefixes      = var.name_prefix == "" ? null : [local.name_prefix]
+  suffixes      = compact([var.client_name, var.environment, local.name_suffix, var.use_caf_naming ? "" : local.route_table_slug])
+  use_slug      = var.use_caf_naming
+  clean_input   = true
+  separator     = "-"
+}
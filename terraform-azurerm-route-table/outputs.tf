@@ -0,0 +1,14 @@
+output "route_table_id" {
+  description = "Id of the created route table"
+  value       = azurerm_route_table.route_table.id
+}
+
+output "route_table_name" {
+  description = "Name of the created route table"
+  value       = azurerm_route_table.route_table.name
+}
+
+output "route_ids" {
+  description = "Ids of the created routes, by route name"
+  value       = { for name, route in azurerm_route.route : name => route.id }
+}
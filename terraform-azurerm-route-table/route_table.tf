@@ -0,0 +1,19 @@
+resource "azurerm_route_table" "route_table" {
+  name                          = local.route_table_name
+  location                      = var.location
+  resource_group_name           = var.resource_group_name
+  disable_bgp_route_propagation = var.disable_bgp_route_propagation
+  tags                          = var.tags
+}
+
+# Routes are separate resources keyed by name, so adding or removing a route does not replace the others
+resource "azurerm_route" "route" {
+  for_each = { for route in var.routes : route.name => route }
+
+  name                   = each.key
+  resource_group_name    = var.resource_group_name
+  route_table_name       = azurerm_route_table.route_table.name
+  address_prefix         = each.value.address_prefix
+  next_hop_type          = each.value.next_hop_type
+  next_hop_in_ip_address = each.value.next_hop_in_ip_address
+}
@@ -0,0 +1,259 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	th "terratest-helpers"
+)
+
+// Global test variables
+var (
+	location                      = "CanadaCentral"
+	clientName                    = "client"
+	environment                   = "test"
+	stack                         = "stack"
+	setupTerraformDir             = "terraform"
+	moduleTerraformDir            = "../"
+	subnetTerraformDir            = "../../terraform-azurerm-subnet"
+	policyDir                     = "../../policies"
+	testModuleDir                 = "module/"
+	testSetupDir                  = "terraform/"
+	testSubnetDir                 = "subnet/"
+	testModuleTerraformOptionsDir = "routeTableTerraformOptions/"
+	testSubnetTerraformOptionsDir = "subnetTerraformOptions/"
+	testSetupTerraformOptionsDir  = "setupTerraformOptions/"
+)
+
+type RouteTableTestData struct {
+	vNetRgName                 string
+	vNetCidr                   string
+	vNetName                   string
+	subnetCidr                 string
+	expectedRouteTableName     string
+	disableBGPRoutePropagation bool
+	routes                     []Route
+}
+
+// A route of the routes variable. The next hop IP address is only set on the routes to a VirtualAppliance.
+type Route struct {
+	name             string
+	addressPrefix    string
+	nextHopType      string
+	nextHopIPAddress string
+}
+
+func TestRouteTableWithRoutes(t *testing.T) {
+	testRootDir := "TestRouteTableWithRoutes/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	// Traffic leaving the subnet goes through a firewall appliance, except for the blackholed range
+	testData := RouteTableTestData{
+		vNetRgName:                 fmt.Sprintf("rg-route-unit-test-%s", nameSuffix),
+		vNetCidr:                   "10.11.0.0/16",
+		vNetName:                   fmt.Sprintf("vnet-route-unit-test-%s", nameSuffix),
+		subnetCidr:                 "10.11.0.0/24",
+		expectedRouteTableName:     fmt.Sprintf("route-stack-client-test-%s", nameSuffix),
+		disableBGPRoutePropagation: true,
+		routes: []Route{
+			{
+				name:             "default-to-firewall",
+				addressPrefix:    "0.0.0.0/0",
+				nextHopType:      "VirtualAppliance",
+				nextHopIPAddress: "10.11.1.4",
+			},
+			{
+				name:          "vnet-local",
+				addressPrefix: "10.11.0.0/16",
+				nextHopType:   "VnetLocal",
+			},
+			{
+				name:          "blackhole",
+				addressPrefix: "192.168.0.0/16",
+				nextHopType:   "None",
+			},
+		},
+	}
+
+	RouteTable(t, testRootDir, nameSuffix, testData)
+}
+
+func TestRouteTableWithoutRoutes(t *testing.T) {
+	testRootDir := "TestRouteTableWithoutRoutes/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	// Only the system routes of Azure and the routes learned by BGP apply
+	testData := RouteTableTestData{
+		vNetRgName:                 fmt.Sprintf("rg-route-unit-test-%s", nameSuffix),
+		vNetCidr:                   "10.12.0.0/16",
+		vNetName:                   fmt.Sprintf("vnet-route-unit-test-%s", nameSuffix),
+		subnetCidr:                 "10.12.0.0/24",
+		expectedRouteTableName:     fmt.Sprintf("route-stack-client-test-%s", nameSuffix),
+		disableBGPRoutePropagation: false,
+		routes:                     []Route{},
+	}
+
+	RouteTable(t, testRootDir, nameSuffix, testData)
+}
+
+func RouteTable(t *testing.T, testRootDir string, nameSuffix string, testData RouteTableTestData) {
+	// At the end of the test, clean up resources.
+	defer th.RunTestStage(t, "teardown_"+testRootDir, func() {
+		TearDown(t, testRootDir)
+	})
+
+	// Lint the module copied into the testRootDir before deploying anything (the setup stage removes the copy, and the
+	// deploy stage copies the module again)
+	th.RunTestStage(t, "lint_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+		th.LintModule(t, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+	})
+
+	th.RunTestStage(t, "setup_"+testRootDir, func() {
+		// If state files exist, clean up resources
+		TearDown(t, testRootDir)
+		th.CopyTerraformFolder(t, setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
+		ts.SaveString(t, testRootDir, "nameSuffix", nameSuffix)
+
+		setupTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir), map[string]interface{}{
+			"config": map[string]interface{}{
+				"location":            location,
+				"resource_group_name": testData.vNetRgName,
+				"vnet_name":           testData.vNetName,
+				"address_space":       []string{testData.vNetCidr},
+			},
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir, setupTerraformOptions)
+		th.InitAndApply(t, setupTerraformOptions)
+	})
+
+	th.RunTestStage(t, "deploy_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+
+		routeTableTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir), map[string]interface{}{
+			"location":                      location,
+			"resource_group_name":           testData.vNetRgName,
+			"name_suffix":                   nameSuffix,
+			"client_name":                   clientName,
+			"environment":                   environment,
+			"stack":                         stack,
+			"disable_bgp_route_propagation": testData.disableBGPRoutePropagation,
+			"routes":                        RoutesVariable(testData.routes),
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir, routeTableTerraformOptions)
+
+		// Check the module plan against the security policies before applying it
+		th.RunTestStage(t, "policy_"+testRootDir, func() {
+			th.CheckPlanPolicies(t, routeTableTerraformOptions, policyDir, th.PolicyOptions{})
+		})
+		th.InitAndApply(t, routeTableTerraformOptions)
+
+		// Associate a subnet of the subnet module with the route table
+		th.CopyTerraformFolder(t, subnetTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSubnetDir))
+
+		subnetTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testSubnetDir), map[string]interface{}{
+			"vnet_resource_group_name": testData.vNetRgName,
+			"vnet_name":                testData.vNetName,
+			"name_suffix":              nameSuffix,
+			"client_name":              clientName,
+			"environment":              environment,
+			"stack":                    stack,
+			"subnets": map[string]interface{}{
+				"routed": map[string]interface{}{
+					"cidr_list":        []string{testData.subnetCidr},
+					"route_table_name": terraform.Output(t, routeTableTerraformOptions, "route_table_name"),
+				},
+			},
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testSubnetTerraformOptionsDir, subnetTerraformOptions)
+		th.InitAndApply(t, subnetTerraformOptions)
+	})
+
+	th.RunTestStage(t, "validate_"+testRootDir, func() {
+		routeTableTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir)
+		routeTableID := terraform.Output(t, routeTableTerraformOptions, "route_table_id")
+		deployedRouteTable := th.GetAzureResource(t, routeTableID)
+
+		// Ensure that the route table has exactly the routes of the test data, with the expected address prefix, next
+		// hop type and next hop IP address. Missing and extra routes are reported along with the mismatching fields of
+		// each route.
+		expectedRoutes := []th.RouteExpectation{}
+		for _, route := range testData.routes {
+			expectedRoutes = append(expectedRoutes, th.RouteExpectation{
+				Name:             route.name,
+				AddressPrefix:    route.addressPrefix,
+				NextHopType:      route.nextHopType,
+				NextHopIPAddress: route.nextHopIPAddress,
+			})
+		}
+		th.AssertExpectation(t, deployedRouteTable, th.RouteTableExpectation{
+			Name:                       testData.expectedRouteTableName,
+			Location:                   location,
+			DisableBGPRoutePropagation: &testData.disableBGPRoutePropagation,
+			Routes:                     expectedRoutes,
+		})
+
+		// Ensure that the module outputs the ID of every route
+		routeIDs := terraform.OutputMap(t, routeTableTerraformOptions, "route_ids")
+		th.Assert(t).Len(routeIDs, len(testData.routes))
+		for _, route := range testData.routes {
+			th.Assert(t).Contains(routeIDs, route.name)
+		}
+
+		// Ensure that the associated subnet reports the route table
+		subnetTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testSubnetTerraformOptionsDir)
+		subnetIDs := terraform.OutputMap(t, subnetTerraformOptions, "subnet_ids")
+		deployedSubnet := th.GetAzureResource(t, subnetIDs["routed"])
+		th.AssertExpectation(t, deployedSubnet, th.SubnetExpectation{RouteTableID: routeTableID})
+	})
+}
+
+// Returns the routes variable of the module
+func RoutesVariable(routes []Route) []map[string]interface{} {
+	variable := []map[string]interface{}{}
+	for _, route := range routes {
+		value := map[string]interface{}{
+			"name":           route.name,
+			"address_prefix": route.addressPrefix,
+			"next_hop_type":  route.nextHopType,
+		}
+		if route.nextHopIPAddress != "" {
+			value["next_hop_in_ip_address"] = route.nextHopIPAddress
+		}
+		variable = append(variable, value)
+	}
+	return variable
+}
+
+// Destroys the subnet, the route table and the setup resources (in that order, as the subnet depends on the route
+// table) and removes the testRootDir
+func TearDown(t *testing.T, testRootDir string) {
+	th.TearDown(t, testRootDir, testSubnetTerraformOptionsDir, testModuleTerraformOptionsDir, testSetupTerraformOptionsDir)
+}
@@ -0,0 +1,59 @@
+# General configs
+
+variable "location" {
+  type        = string
+  description = "Azure region for resource deployment"
+}
+
+variable "resource_group_name" {
+  type        = string
+  description = "Resource group that the route table lies in"
+}
+
+variable "tags" {
+  type        = map(string)
+  description = "Tags to add to the route table"
+  default     = {}
+}
+
+# Routes
+
+variable "disable_bgp_route_propagation" {
+  type        = bool
+  description = "Disable the propagation of the routes learned by BGP (eg. from a VPN or ExpressRoute gateway) to the associated subnets"
+  default     = false
+}
+
+variable "routes" {
+  type = list(object({
+    name                   = string
+    address_prefix         = string
+    next_hop_type          = string
+    next_hop_in_ip_address = optional(string)
+  }))
+  description = <<EOD
+User defined routes of the route table
+list(object({
+  name                   = string # Unique name of the route
+  address_prefix         = string # CIDR or service tag (eg. AzureCloud) the route applies to
+  next_hop_type          = string # VirtualNetworkGateway, VnetLocal, Internet, VirtualAppliance or None
+  next_hop_in_ip_address = string # IP address of the next hop, only set when next_hop_type is VirtualAppliance
+}))
+EOD
+  default = []
+
+  validation {
+    condition     = length(distinct([for route in var.routes : route.name])) == length(var.routes)
+    error_message = "The route names must be unique."
+  }
+
+  validation {
+    condition     = alltrue([for route in var.routes : contains(["VirtualNetworkGateway", "VnetLocal", "Internet", "VirtualAppliance", "None"], route.next_hop_type)])
+    error_message = "The route next hop types must be VirtualNetworkGateway, VnetLocal, Internet, VirtualAppliance or None."
+  }
+
+  validation {
+    condition     = alltrue([for route in var.routes : (route.next_hop_type == "VirtualAppliance") == (route.next_hop_in_ip_address != null)])
+    error_message = "The next hop IP address must be set on the routes to a VirtualAppliance, and only on those."
+  }
+}
@@ -1,19 +1,21 @@
 locals {
-  name_prefix               = var.name_prefix 
-  name_suffix               = var.name_suffix
-  snet_slug                 = "snet"
-  subnet_name               = coalesce(var.custom_subnet_name, azurecaf_name.subnet.result)
-  network_security_group_rg = coalesce(var.network_security_group_rg, var.vnet_resource_group_name)
-  route_table_rg            = coalesce(var.route_table_rg, var.vnet_resource_group_name)
-  network_security_group_id = format("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/networkSecurityGroups/%s", data.azurerm_subscription.current.subscription_id, local.network_security_group_rg, coalesce(var.network_security_group_name, "fake"))
-  route_table_id            = format("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/routeTables/%s", data.azurerm_subscription.current.subscription_id, local.route_table_rg, coalesce(var.route_table_name, "fake"))
+  name_prefix  = var.name_prefix 
+  name_suffix  = var.name_suffix
+  snet_slug    = "snet"
+  subnet_names = { for key, subnet in var.subnets : key => coalesce(subnet.custom_name, azurecaf_name.subnet[key].result) }
+  # Only the subnets with a network security group or route table are associated, by subnet key
+  network_security_group_ids = { for key, subnet in var.subnets : key => format("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/networkSecurityGroups/%s", data.azurerm_subscription.current.subscription_id, coalesce(subnet.network_security_group_rg, var.vnet_resource_group_name), subnet.network_security_group_name) if subnet.network_security_group_name != null }
+  route_table_ids            = { for key, subnet in var.subnets : key => format("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/routeTables/%s", data.azurerm_subscription.current.subscription_id, coalesce(subnet.route_table_rg, var.vnet_resource_group_name), subnet.route_table_name) if subnet.route_table_name != null }
 }
 
 resource "azurecaf_name" "subnet" {
+  for_each = var.subnets
+
   name          = var.stack
   resource_type = "azurerm_subnet"
   prefixes      = var.name_prefix == "" ? null : [local.name_prefix]
-  suffixes      = compact([var.client_name, var.environment, local.name_suffix, var.use_caf_naming ? "" : local.snet_slug])
+  # The subnet key is always part of the name, so adding or removing a subnet never renames the others
+  suffixes      = compact([var.client_name, var.environment, each.key, local.name_suffix, var.use_caf_naming ? "" : local.snet_slug])
   use_slug      = var.use_caf_naming
   clean_input   = true
   separator     = "-"
@@ -1,26 +1,29 @@
 output "subnet_id" {
-  description = "Id of the created subnet"
-  value       = azurerm_subnet.subnet.id
+  description = "Id of the created subnet when a single subnet is created (null otherwise, see subnet_ids)"
+  value       = length(azurerm_subnet.subnet) == 1 ? one(values(azurerm_subnet.subnet)).id : null
+}
+
+output "subnet_ids" {
+  description = "Ids of the created subnets, by subnet key"
+  value       = { for key, subnet in azurerm_subnet.subnet : key => subnet.id }
 }
 
 output "subnet_cidr_list" {
   description = "CIDR list of the created subnets"
-  value       = azurerm_subnet.subnet.address_prefixes
+  value       = flatten([for subnet in azurerm_subnet.subnet : subnet.address_prefixes])
 }
 
 output "subnet_cidrs_map" {
-  description = "Map with names and CIDRs of the created subnets"
-  value = {
-    (azurerm_subnet.subnet.name) = azurerm_subnet.subnet.address_prefixes
-  }
+  description = "CIDRs of the created subnets, by subnet key"
+  value       = { for key, subnet in azurerm_subnet.subnet : key => subnet.address_prefixes }
 }
 
 output "subnet_names" {
-  description = "Names of the created subnet"
-  value       = azurerm_subnet.subnet.name
+  description = "Names of the created subnets, by subnet key"
+  value       = { for key, subnet in azurerm_subnet.subnet : key => subnet.name }
 }
 
 output "subnet_ips" {
-  description = "The collection of IPs within this subnet"
-  value       = var.subnet_cidr_list[*]
+  description = "The collection of IPs within the created subnets, by subnet key"
+  value       = { for key, subnet in var.subnets : key => subnet.cidr_list }
 }
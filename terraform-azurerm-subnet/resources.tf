@@ -1,14 +1,16 @@
 resource "azurerm_subnet" "subnet" {
-  name                                          = local.subnet_name
+  for_each = var.subnets
+
+  name                                          = local.subnet_names[each.key]
   resource_group_name                           = var.vnet_resource_group_name
   virtual_network_name                          = var.vnet_name
-  address_prefixes                              = var.subnet_cidr_list
-  service_endpoints                             = var.service_endpoints
-  private_endpoint_network_policies_enabled     = var.private_endpoint_enabled
-  private_link_service_network_policies_enabled = var.private_link_service_enabled
+  address_prefixes                              = each.value.cidr_list
+  service_endpoints                             = each.value.service_endpoints
+  private_endpoint_network_policies_enabled     = each.value.private_endpoint_enabled
+  private_link_service_network_policies_enabled = each.value.private_link_service_enabled
 
   dynamic "delegation" {
-    for_each = var.subnet_delegation
+    for_each = each.value.delegation
     content {
       name = delegation.key
       dynamic "service_delegation" {
@@ -24,17 +26,17 @@ resource "azurerm_subnet" "subnet" {
 }
 
 resource "azurerm_subnet_network_security_group_association" "subnet_association" {
-  count = var.network_security_group_name == null ? 0 : 1
+  for_each = local.network_security_group_ids
 
-  subnet_id                 = azurerm_subnet.subnet.id
-  network_security_group_id = local.network_security_group_id
+  subnet_id                 = azurerm_subnet.subnet[each.key].id
+  network_security_group_id = each.value
 }
 
 resource "azurerm_subnet_route_table_association" "route_table_association" {
-  count = var.route_table_name == null ? 0 : 1
+  for_each = local.route_table_ids
 
-  subnet_id      = azurerm_subnet.subnet.id
-  route_table_id = local.route_table_id
+  subnet_id      = azurerm_subnet.subnet[each.key].id
+  route_table_id = each.value
 }
 
 data "azurerm_subscription" "current" {
@@ -2,33 +2,27 @@ package test
 
 import (
 	"fmt"
-	"os"
-	"strings"
 	"testing"
 
-	"github.com/gruntwork-io/terratest/modules/azure"
 	"github.com/gruntwork-io/terratest/modules/terraform"
-	f "github.com/gruntwork-io/terratest/modules/files"
 	ts "github.com/gruntwork-io/terratest/modules/test-structure"
-	cp "github.com/otiai10/copy"
-	"github.com/stretchr/testify/assert"
-	"github.com/thanhpk/randstr"
+	th "terratest-helpers"
 )
 
 // Global test variables
 var (
-	subscriptionID     = "<private_value>"
-	location           = "CanadaCentral"
-	clientName         = "client"
-	environment        = "test"
-	stack              = "stack"
-	suffixLength       = 8
-	setupTerraformDir  = "terraform"
-	moduleTerraformDir = "../"
-	testModuleDir        = "module/"
-	testSetupDir         = "terraform/"
+	subscriptionID                = "<private_value>"
+	location                      = "CanadaCentral"
+	clientName                    = "client"
+	environment                   = "test"
+	stack                         = "stack"
+	setupTerraformDir             = "terraform"
+	moduleTerraformDir            = "../"
+	policyDir                     = "../../policies"
+	testModuleDir                 = "module/"
+	testSetupDir                  = "terraform/"
 	testModuleTerraformOptionsDir = "subnetTerraformOptions/"
-	testSetupTerraformOptionsDir = "setupTerraformOptions/"
+	testSetupTerraformOptionsDir  = "setupTerraformOptions/"
 )
 
 type SubnetTestData struct {
@@ -36,362 +30,292 @@ type SubnetTestData struct {
 	// Variables for vnet
 	vNetCidr string
 	vNetName string
-	// Variables for subnet
-	subnetCidr string
-	privateEndpointEnabled bool
-	privateLinkServiceEnabled bool
-	expectedSubnetName string
+	// Variables for the subnets, by subnet key
+	subnets map[string]SubnetData
 }
 
-type SubnetWithServiceEndpointsTestData struct {
-	vNetRgName string
-	// Variables for vnet
-	vNetCidr string
-	vNetName string
-	// Variables for subnet
-	subnetCidr string
-	privateEndpointEnabled bool
+type SubnetData struct {
+	subnetCidr                string
+	privateEndpointEnabled    bool
 	privateLinkServiceEnabled bool
-	expectedSubnetName string
-	serviceEndpoints []string
+	expectedSubnetName        string
+	serviceEndpoints          []string
 }
 
 func TestSubnetWithDefaultConfigs(t *testing.T) {
 	testRootDir := "TestSubnetWithDefaultConfigs/"
 
 	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
 	// os.Setenv("SKIP_setup_" + testRootDir, "true")
 	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
 	// os.Setenv("SKIP_validate_" + testRootDir, "true")
 	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
 
 	t.Parallel() // Remove to test serially
 
-	nameSuffix := GetNameSuffix(t, testRootDir)
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
 
-	testData := SubnetTestData {
+	testData := SubnetTestData{
 		vNetRgName: fmt.Sprintf("rg-snet-unit-test-%s", nameSuffix),
-		vNetCidr: "10.0.0.0/16",
-		vNetName: fmt.Sprintf("vnet-snet-unit-test-%s", nameSuffix),
-		subnetCidr: "10.0.0.0/24",
-		privateEndpointEnabled: false,
-		privateLinkServiceEnabled: false,
-		expectedSubnetName: fmt.Sprintf("snet-stack-client-test-%s", nameSuffix),
-	}
-
-	SubnetWithDefaultConfigs(t, testRootDir, nameSuffix, testData)
-}
-
-
-func SubnetWithDefaultConfigs(t *testing.T, testRootDir string, nameSuffix string, testData SubnetTestData) {
-	// At the end of the test, clean up resources.
-	defer ts.RunTestStage(t, "teardown_" + testRootDir, func() {
-		TearDown(t, testRootDir)
-	})
-
-	ts.RunTestStage(t, "setup_" + testRootDir, func() {
-		// If state files exist, clean up resources
-		TearDown(t, testRootDir)
-		CopyTerraformFolder(setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
-		ts.SaveString(t, testRootDir, "nameSuffix", nameSuffix)
-		
-		setupTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-			TerraformDir: fmt.Sprintf("%s%s", testRootDir, testSetupDir),
-			Vars: map[string]interface{}{
-				"config": map[string]interface{}{
-					"location":            location,
-					"resource_group_name": testData.vNetRgName,
-					"vnet_name":           testData.vNetName,
-					"address_space":       []string{testData.vNetCidr},
-				},
+		vNetCidr:   "10.0.0.0/16",
+		vNetName:   fmt.Sprintf("vnet-snet-unit-test-%s", nameSuffix),
+		subnets: map[string]SubnetData{
+			"default": {
+				subnetCidr:                "10.0.0.0/24",
+				privateEndpointEnabled:    false,
+				privateLinkServiceEnabled: false,
+				expectedSubnetName:        fmt.Sprintf("snet-stack-client-test-default-%s", nameSuffix),
 			},
-		})
-
-		ts.SaveTerraformOptions(t, fmt.Sprintf("%s%s", testRootDir, testSetupTerraformOptionsDir), setupTerraformOptions)
-		terraform.InitAndApply(t, setupTerraformOptions)
-	})
-
-	ts.RunTestStage(t, "deploy_" + testRootDir, func() {
-		CopyTerraformFolder(moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
-
-		subnetTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-			TerraformDir: fmt.Sprintf("%s%s", testRootDir, testModuleDir),
-			Vars: map[string]interface{}{
-				"vnet_resource_group_name":     testData.vNetRgName,
-				"vnet_name":                    testData.vNetName,
-				"name_suffix":                  nameSuffix,
-				"stack":                        stack,
-				"environment":                  environment,
-				"client_name":                  clientName,
-				"subnet_cidr_list":             []string{testData.subnetCidr},
-				"private_endpoint_enabled":     testData.privateEndpointEnabled,
-				"private_link_service_enabled": testData.privateLinkServiceEnabled,
-			},
-		})
-
-		ts.SaveTerraformOptions(t, fmt.Sprintf("%s%s", testRootDir, testModuleTerraformOptionsDir), subnetTerraformOptions)
-
-		terraform.InitAndApply(t, subnetTerraformOptions)
-	})
+		},
+	}
 
-	ts.RunTestStage(t, "validate_" + testRootDir, func() {		
-		vNetSubnets := azure.GetVirtualNetworkSubnets(t, testData.vNetName, testData.vNetRgName, subscriptionID)
-		// Ensure subnet is present in the virtual network
-		assert.NotNil(t, vNetSubnets[testData.expectedSubnetName])
-		// Ensure subnet has the correct address space
-		assert.Equal(t, testData.subnetCidr, vNetSubnets[testData.expectedSubnetName])
-		// Get the subnet and store in object
-		deployedSubnet, err := azure.GetSubnetE(testData.expectedSubnetName, testData.vNetName, testData.vNetRgName, subscriptionID)
-		assert.Nil(t, err)
-		// Get the subnet's properties
-		deployedSubnetProperties := deployedSubnet.SubnetPropertiesFormat
-		// Ensure that private endpoint link, and endpoint policies are disabled
-		assert.Equal(t, *deployedSubnetProperties.PrivateEndpointNetworkPolicies, "Disabled")
-		assert.Equal(t, *deployedSubnetProperties.PrivateLinkServiceNetworkPolicies, "Disabled")
-	})
+	SubnetsWithConfigs(t, testRootDir, nameSuffix, testData)
 }
 
 func TestSubnetWithPrivatePolicies(t *testing.T) {
 	testRootDir := "TestSubnetWithPrivatePolicies/"
 
 	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
 	// os.Setenv("SKIP_setup_" + testRootDir, "true")
 	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
 	// os.Setenv("SKIP_validate_" + testRootDir, "true")
 	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
 
 	t.Parallel() // Remove to test serially
 
-	nameSuffix := GetNameSuffix(t, testRootDir)
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
 
-	testData := SubnetTestData {
+	testData := SubnetTestData{
 		vNetRgName: fmt.Sprintf("rg-snet-unit-test-%s", nameSuffix),
-		vNetCidr: "10.2.0.0/16",
-		vNetName: fmt.Sprintf("vnet-snet-unit-test-%s", nameSuffix),
-		subnetCidr: "10.2.0.0/24",
-		privateEndpointEnabled: true,
-		privateLinkServiceEnabled: true,
-		expectedSubnetName: fmt.Sprintf("snet-stack-client-test-%s", nameSuffix),
+		vNetCidr:   "10.2.0.0/16",
+		vNetName:   fmt.Sprintf("vnet-snet-unit-test-%s", nameSuffix),
+		subnets: map[string]SubnetData{
+			"private": {
+				subnetCidr:                "10.2.0.0/24",
+				privateEndpointEnabled:    true,
+				privateLinkServiceEnabled: true,
+				expectedSubnetName:        fmt.Sprintf("snet-stack-client-test-private-%s", nameSuffix),
+			},
+		},
 	}
 
-	SubnetWithPrivatePolicies(t, testRootDir, nameSuffix, testData)
+	SubnetsWithConfigs(t, testRootDir, nameSuffix, testData)
 }
 
-func SubnetWithPrivatePolicies(t *testing.T, testRootDir string, nameSuffix string, testData SubnetTestData) {
-	// At the end of the test, clean up resources.
-	defer ts.RunTestStage(t, "teardown_" + testRootDir, func() {
-		TearDown(t, testRootDir)
-	})
-
-	ts.RunTestStage(t, "setup_" + testRootDir, func() {
-		// If state files exist, clean up resources
-		TearDown(t, testRootDir)
-		CopyTerraformFolder(setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
-		ts.SaveString(t, testRootDir, "nameSuffix", nameSuffix)
-		
-		setupTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-			TerraformDir: fmt.Sprintf("%s%s", testRootDir, testSetupDir),
-			Vars: map[string]interface{}{
-				"config": map[string]interface{}{
-					"location":            location,
-					"resource_group_name": testData.vNetRgName,
-					"vnet_name":           testData.vNetName,
-					"address_space":       []string{testData.vNetCidr},
-				},
-			},
-		})
+func TestSubnetWithServiceEndpoints(t *testing.T) {
+	testRootDir := "TestSubnetWithServiceEndpoints/"
 
-		ts.SaveTerraformOptions(t, fmt.Sprintf("%s%s", testRootDir, testSetupTerraformOptionsDir), setupTerraformOptions)
-		terraform.InitAndApply(t, setupTerraformOptions)
-	})
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
 
-	ts.RunTestStage(t, "deploy_" + testRootDir, func() {
-		CopyTerraformFolder(moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
-
-		subnetTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-			TerraformDir: fmt.Sprintf("%s%s", testRootDir, testModuleDir),
-			Vars: map[string]interface{}{
-				"vnet_resource_group_name":     testData.vNetRgName,
-				"vnet_name":                    testData.vNetName,
-				"name_suffix":                  nameSuffix,
-				"stack":                        stack,
-				"environment":                  environment,
-				"client_name":                  clientName,
-				"subnet_cidr_list":             []string{testData.subnetCidr},
-				"private_endpoint_enabled":     testData.privateEndpointEnabled,
-				"private_link_service_enabled": testData.privateLinkServiceEnabled,
-			},
-		})
+	t.Parallel() // Remove to test serially
 
-		ts.SaveTerraformOptions(t, fmt.Sprintf("%s%s", testRootDir, testModuleTerraformOptionsDir), subnetTerraformOptions)
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
 
-		terraform.InitAndApply(t, subnetTerraformOptions)
-	})
+	testData := SubnetTestData{
+		vNetRgName: fmt.Sprintf("rg-snet-unit-test-%s", nameSuffix),
+		vNetCidr:   "10.4.0.0/16",
+		vNetName:   fmt.Sprintf("vnet-snet-unit-test-%s", nameSuffix),
+		subnets: map[string]SubnetData{
+			"endpoints": {
+				subnetCidr:                "10.4.0.0/24",
+				privateEndpointEnabled:    false,
+				privateLinkServiceEnabled: false,
+				expectedSubnetName:        fmt.Sprintf("snet-stack-client-test-endpoints-%s", nameSuffix),
+				serviceEndpoints:          []string{"Microsoft.Storage", "Microsoft.Sql", "Microsoft.ServiceBus"},
+			},
+		},
+	}
 
-	ts.RunTestStage(t, "validate_" + testRootDir, func() {
-		vNetSubnets := azure.GetVirtualNetworkSubnets(t, testData.vNetName, testData.vNetRgName, subscriptionID)
-		// Ensure subnet is present in the virtual network
-		assert.NotNil(t, vNetSubnets[testData.expectedSubnetName])
-		// Ensure subnet has the correct address space
-		assert.Equal(t, testData.subnetCidr, vNetSubnets[testData.expectedSubnetName])
-		// Get the subnet and store in object
-		deployedSubnet, err := azure.GetSubnetE(testData.expectedSubnetName, testData.vNetName, testData.vNetRgName, subscriptionID)
-		assert.Nil(t, err)
-		// Get the subnet's properties
-		deployedSubnetProperties := deployedSubnet.SubnetPropertiesFormat
-		// Ensure that private endpoint link, and endpoint policies are enabled
-		assert.Equal(t, *deployedSubnetProperties.PrivateEndpointNetworkPolicies, "Enabled")
-		assert.Equal(t, *deployedSubnetProperties.PrivateLinkServiceNetworkPolicies, "Enabled")
-	})
+	SubnetsWithConfigs(t, testRootDir, nameSuffix, testData)
 }
 
-func TestSubnetWithServiceEndpoints(t *testing.T) {
-	testRootDir := "TestSubnetWithServiceEndpoints/"
+func TestMultipleSubnets(t *testing.T) {
+	testRootDir := "TestMultipleSubnets/"
 
 	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
 	// os.Setenv("SKIP_setup_" + testRootDir, "true")
 	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
 	// os.Setenv("SKIP_validate_" + testRootDir, "true")
 	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
 
 	t.Parallel() // Remove to test serially
 
-	nameSuffix := GetNameSuffix(t, testRootDir)
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
 
-	testData := SubnetWithServiceEndpointsTestData {
+	// Every subnet has its own configuration, and all of them are deployed and validated at once
+	testData := SubnetTestData{
 		vNetRgName: fmt.Sprintf("rg-snet-unit-test-%s", nameSuffix),
-		vNetCidr: "10.4.0.0/16",
-		vNetName: fmt.Sprintf("vnet-snet-unit-test-%s", nameSuffix),
-		subnetCidr: "10.4.0.0/24",
-		privateEndpointEnabled: false,
-		privateLinkServiceEnabled: false,
-		expectedSubnetName: fmt.Sprintf("snet-stack-client-test-%s", nameSuffix),
-		serviceEndpoints: []string{"Microsoft.Storage", "Microsoft.Sql", "Microsoft.ServiceBus"},
+		vNetCidr:   "10.6.0.0/16",
+		vNetName:   fmt.Sprintf("vnet-snet-unit-test-%s", nameSuffix),
+		subnets: map[string]SubnetData{
+			"app": {
+				subnetCidr:                "10.6.0.0/24",
+				privateEndpointEnabled:    false,
+				privateLinkServiceEnabled: false,
+				expectedSubnetName:        fmt.Sprintf("snet-stack-client-test-app-%s", nameSuffix),
+				serviceEndpoints:          []string{"Microsoft.Storage"},
+			},
+			"data": {
+				subnetCidr:                "10.6.1.0/24",
+				privateEndpointEnabled:    true,
+				privateLinkServiceEnabled: false,
+				expectedSubnetName:        fmt.Sprintf("snet-stack-client-test-data-%s", nameSuffix),
+				serviceEndpoints:          []string{"Microsoft.Sql", "Microsoft.KeyVault"},
+			},
+			"private": {
+				subnetCidr:                "10.6.2.0/24",
+				privateEndpointEnabled:    true,
+				privateLinkServiceEnabled: true,
+				expectedSubnetName:        fmt.Sprintf("snet-stack-client-test-private-%s", nameSuffix),
+			},
+		},
 	}
 
-	SubnetWithServiceEndpoints(t, testRootDir, nameSuffix, testData)
+	SubnetsWithConfigs(t, testRootDir, nameSuffix, testData)
 }
 
-func SubnetWithServiceEndpoints(t *testing.T, testRootDir string, nameSuffix string, testData SubnetWithServiceEndpointsTestData) {
+func SubnetsWithConfigs(t *testing.T, testRootDir string, nameSuffix string, testData SubnetTestData) {
 	// At the end of the test, clean up resources.
-	defer ts.RunTestStage(t, "teardown_" + testRootDir, func() {
+	defer th.RunTestStage(t, "teardown_"+testRootDir, func() {
 		TearDown(t, testRootDir)
 	})
 
-	ts.RunTestStage(t, "setup_" + testRootDir, func() {
+	// Lint the module copied into the testRootDir before deploying anything (the setup stage removes the copy, and the
+	// deploy stage copies the module again)
+	th.RunTestStage(t, "lint_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+		th.LintModule(t, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+	})
+
+	th.RunTestStage(t, "setup_"+testRootDir, func() {
 		// If state files exist, clean up resources
 		TearDown(t, testRootDir)
-		CopyTerraformFolder(setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
+		th.CopyTerraformFolder(t, setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
 		ts.SaveString(t, testRootDir, "nameSuffix", nameSuffix)
-		
-		setupTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-			TerraformDir: fmt.Sprintf("%s%s", testRootDir, testSetupDir),
-			Vars: map[string]interface{}{
-				"config": map[string]interface{}{
-					"location":            location,
-					"resource_group_name": testData.vNetRgName,
-					"vnet_name":           testData.vNetName,
-					"address_space":       []string{testData.vNetCidr},
-				},
+
+		setupTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir), map[string]interface{}{
+			"config": map[string]interface{}{
+				"location":            location,
+				"resource_group_name": testData.vNetRgName,
+				"vnet_name":           testData.vNetName,
+				"address_space":       []string{testData.vNetCidr},
 			},
 		})
 
-		ts.SaveTerraformOptions(t, fmt.Sprintf("%s%s", testRootDir, testSetupTerraformOptionsDir), setupTerraformOptions)
-		terraform.InitAndApply(t, setupTerraformOptions)
+		th.SaveTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir, setupTerraformOptions)
+		th.InitAndApply(t, setupTerraformOptions)
 	})
 
-	ts.RunTestStage(t, "deploy_" + testRootDir, func() {
-		CopyTerraformFolder(moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
-
-		subnetTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-			TerraformDir: fmt.Sprintf("%s%s", testRootDir, testModuleDir),
-			Vars: map[string]interface{}{
-				"vnet_resource_group_name":     testData.vNetRgName,
-				"vnet_name":                    testData.vNetName,
-				"name_suffix":                  nameSuffix,
-				"stack":                        stack,
-				"environment":                  environment,
-				"client_name":                  clientName,
-				"subnet_cidr_list":             []string{testData.subnetCidr},
-				"private_endpoint_enabled":     testData.privateEndpointEnabled,
-				"private_link_service_enabled": testData.privateLinkServiceEnabled,
-				"service_endpoints":            testData.serviceEndpoints,
-			},
+	th.RunTestStage(t, "deploy_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+
+		subnets := map[string]interface{}{}
+		for key, subnet := range testData.subnets {
+			subnets[key] = map[string]interface{}{
+				"cidr_list":                    []string{subnet.subnetCidr},
+				"private_endpoint_enabled":     subnet.privateEndpointEnabled,
+				"private_link_service_enabled": subnet.privateLinkServiceEnabled,
+				"service_endpoints":            subnet.serviceEndpoints,
+			}
+		}
+
+		subnetTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir), map[string]interface{}{
+			"vnet_resource_group_name": testData.vNetRgName,
+			"vnet_name":                testData.vNetName,
+			"name_suffix":              nameSuffix,
+			"stack":                    stack,
+			"environment":              environment,
+			"client_name":              clientName,
+			"subnets":                  subnets,
 		})
 
-		ts.SaveTerraformOptions(t, fmt.Sprintf("%s%s", testRootDir, testModuleTerraformOptionsDir), subnetTerraformOptions)
+		th.SaveTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir, subnetTerraformOptions)
 
-		terraform.InitAndApply(t, subnetTerraformOptions)
+		// Check the module plan against the security policies before applying it
+		th.RunTestStage(t, "policy_"+testRootDir, func() {
+			th.CheckPlanPolicies(t, subnetTerraformOptions, policyDir, th.PolicyOptions{
+				// The test subnets are not associated with a network security group
+				Exceptions: map[string][]string{"subnet_nsg": {"azurerm_subnet.subnet"}},
+			})
+		})
+		th.InitAndApply(t, subnetTerraformOptions)
 	})
 
-	ts.RunTestStage(t, "validate_" + testRootDir, func() {
-		vNetSubnets := azure.GetVirtualNetworkSubnets(t, testData.vNetName, testData.vNetRgName, subscriptionID)
-		// Ensure subnet is present in the virtual network
-		assert.NotNil(t, vNetSubnets[testData.expectedSubnetName])
-		// Ensure subnet has the correct address space
-		assert.Equal(t, testData.subnetCidr, vNetSubnets[testData.expectedSubnetName])
-		// Get the subnet and store in object
-		deployedSubnet, err := azure.GetSubnetE(testData.expectedSubnetName, testData.vNetName, testData.vNetRgName, subscriptionID)
-		assert.Nil(t, err)
-		// Get the subnet's properties
-		deployedSubnetProperties := deployedSubnet.SubnetPropertiesFormat
-		// Ensure that private endpoint link, and endpoint policies are enabled
-		assert.Equal(t, *deployedSubnetProperties.PrivateEndpointNetworkPolicies, "Disabled")
-		assert.Equal(t, *deployedSubnetProperties.PrivateLinkServiceNetworkPolicies, "Disabled")
-		deployedServiceEndpoints := *deployedSubnetProperties.ServiceEndpoints
-		deployedServiceEndpointNames := []string{}
-		// Get all service endpoints from deployed subnet
-		for i := 0; i < len(deployedServiceEndpoints); i++ {
-			deployedServiceEndpointNames = append(deployedServiceEndpointNames, *deployedServiceEndpoints[i].Service)
-		}
-		// Ensure that all service endpoints are deployed
-		for i := 0; i < len(testData.serviceEndpoints); i++ {
-			assert.Contains(t, deployedServiceEndpointNames, testData.serviceEndpoints[i])
-		}
+	th.RunTestStage(t, "validate_"+testRootDir, func() {
+		ValidateSubnets(t, testRootDir, testData)
 	})
 }
 
-// Loads the nameSuffix (generating one for setup stage if it does not exist)
-func GetNameSuffix(t *testing.T, testRootDir string) string {
-	nameSuffix := ""
-	if (os.Getenv("SKIP_setup_" + testRootDir) == "true") {
-		if(ts.IsTestDataPresent(t, testRootDir + ".test-data/nameSuffix.json")) {
-			nameSuffix = ts.LoadString(t, testRootDir, "nameSuffix")
+// Validates every subnet output by the module in subnet_cidrs_map against the subnet of the test data with the same key
+func ValidateSubnets(t *testing.T, testRootDir string, testData SubnetTestData) {
+	subnetTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir)
+	subnetCidrsMap := terraform.OutputMapOfObjects(t, subnetTerraformOptions, "subnet_cidrs_map")
+	subnetIDs := terraform.OutputMap(t, subnetTerraformOptions, "subnet_ids")
+	// Ensure every subnet of the test data is deployed
+	th.Assert(t).Len(subnetCidrsMap, len(testData.subnets))
+	// The subnet_id output is only set when a single subnet is created
+	subnetID := terraform.Output(t, subnetTerraformOptions, "subnet_id")
+	if len(testData.subnets) == 1 {
+		for key := range testData.subnets {
+			th.Assert(t).Equal(subnetIDs[key], subnetID)
 		}
 	} else {
-		nameSuffix = strings.ToLower(randstr.String(suffixLength))
-	}	
-	return nameSuffix
-}
+		th.Assert(t).Empty(subnetID)
+	}
+	vNetSubnets := th.GetVirtualNetworkSubnets(t, testData.vNetName, testData.vNetRgName, subscriptionID)
 
-// Copy the module excluding the /test folder and state files
-func CopyTerraformFolder(src string, dest string) {
-	opt := cp.Options{
-		Skip: func(info os.FileInfo, src, dest string) (bool, error) {
-			return strings.HasSuffix(src, "/test") || f.PathContainsTerraformState(src), nil
-		},
+	for key, subnetCidrs := range subnetCidrsMap {
+		subnet, ok := testData.subnets[key]
+		if !th.Assert(t).True(ok, "Unexpected subnet %s output by the module", key) {
+			continue
+		}
+		// Ensure subnet is present in the virtual network, with the correct address space
+		th.Assert(t).Equal(subnet.subnetCidr, vNetSubnets[subnet.expectedSubnetName], "Subnet %s", key)
+		th.Assert(t).Equal([]interface{}{subnet.subnetCidr}, subnetCidrs, "Subnet %s", key)
+
+		// Wait for the service endpoints to be provisioned, as their provisioning state can lag behind the deploy
+		if len(subnet.serviceEndpoints) > 0 {
+			provisionedServiceEndpoints := []string{}
+			for i := 0; i < len(subnet.serviceEndpoints); i++ {
+				provisionedServiceEndpoints = append(provisionedServiceEndpoints, "Succeeded")
+			}
+			th.EventuallyProperty(t, subnetIDs[key], "properties.serviceEndpoints[*].provisioningState", provisionedServiceEndpoints)
+		}
+
+		// Get the subnet through the resource ID output by the module, and ensure that it matches (including its
+		// private endpoint and private link service network policies, and service endpoints)
+		deployedSubnet := th.GetAzureResource(t, subnetIDs[key])
+		th.AssertExpectation(t, deployedSubnet, th.SubnetExpectation{
+			Name:                              subnet.expectedSubnetName,
+			AddressPrefixes:                   []string{subnet.subnetCidr},
+			PrivateEndpointNetworkPolicies:    NetworkPolicies(subnet.privateEndpointEnabled),
+			PrivateLinkServiceNetworkPolicies: NetworkPolicies(subnet.privateLinkServiceEnabled),
+			ServiceEndpoints:                  subnet.serviceEndpoints,
+		}, "Subnet %s", key)
 	}
-	cp.Copy(src, dest, opt)
 }
 
-func TearDown(t *testing.T, testRootDir string) {
-	TearDownTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir)
-	TearDownTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir)
-	os.RemoveAll(testRootDir)
+// Returns the network policies state reported by Azure for the enabled variable of the module
+func NetworkPolicies(enabled bool) string {
+	if enabled {
+		return "Enabled"
+	}
+	return "Disabled"
 }
 
-func TearDownTerraformOptions(t *testing.T, testRootDir string, terraformOptionsDir string) {
-    defer func() {
-        if r := recover(); r != nil {
-            fmt.Printf("Recovered from destroy %s: %v\n", terraformOptionsDir, r)
-        }
-    }()
-
-    if _, err := os.Stat(testRootDir + terraformOptionsDir); err == nil {
-        terraformOptions := ts.LoadTerraformOptions(t, fmt.Sprintf("%s%s", testRootDir, terraformOptionsDir))
-        _, err := terraform.DestroyE(t, terraformOptions)
-		if err != nil {
-			panic(err)
-		}
-    }
+// Destroys the subnet and setup resources and removes the testRootDir
+func TearDown(t *testing.T, testRootDir string) {
+	th.TearDown(t, testRootDir, testModuleTerraformOptionsDir, testSetupTerraformOptionsDir)
 }
@@ -32,11 +32,3 @@ variable "use_caf_naming" {
   type        = bool
   default     = true
 }
-
-# Specific module naming variables
-
-variable "custom_subnet_name" {
-  description = "Optional custom resource subnet name"
-  type        = string
-  default     = ""
-}
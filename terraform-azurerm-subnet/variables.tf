@@ -8,63 +8,35 @@ variable "vnet_name" {
   type        = string
 }
 
-variable "subnet_cidr_list" {
-  description = "The address prefix list to use for the subnet"
-  type        = list(string)
-}
-
-variable "route_table_name" {
-  description = "The Route Table name to associate with the subnet"
-  type        = string
-  default     = null
-}
-
-variable "route_table_rg" {
-  description = "The Route Table RG to associate with the subnet. Default is the same RG than the subnet."
-  type        = string
-  default     = null
-}
-
-variable "network_security_group_name" {
-  description = "The Network Security Group name to associate with the subnets"
-  type        = string
-  default     = null
-}
-
-variable "network_security_group_rg" {
-  description = "The Network Security Group RG to associate with the subnet. Default is the same RG than the subnet."
-  type        = string
-  default     = null
-}
-
-variable "service_endpoints" {
-  description = "The list of Service endpoints to associate with the subnet"
-  type        = list(string)
-  default     = []
-}
-
-variable "private_endpoint_enabled" {
-  description = "Enable or Disable network policies for the private endpoint on the subnet. Setting this to true will Enable the policy and setting this to false will Disable the policy. Defaults to true."
-  type        = bool
-  default     = true
-}
-
-variable "private_link_service_enabled" {
-  description = "Enable or Disable network policies for the private link service on the subnet. Setting this to true will Enable the policy and setting this to false will Disable the policy. Defaults to true."
-  type        = bool
-  default     = true
-}
-
-variable "subnet_delegation" {
+variable "subnets" {
   description = <<EOD
-Configuration delegations on subnet
-object({
-  name = object({
-    name = string,
-    actions = list(string)
-  })
-})
+Subnets to create, by subnet key (used in the generated subnet name, and as the key of the outputs)
+map(object({
+  cidr_list                    = list(string) # The address prefix list to use for the subnet
+  custom_name                  = string       # Optional custom subnet name
+  service_endpoints            = list(string) # The list of Service endpoints to associate with the subnet. Default is [].
+  private_endpoint_enabled     = bool         # Enable or Disable network policies for the private endpoint on the subnet. Default is true.
+  private_link_service_enabled = bool         # Enable or Disable network policies for the private link service on the subnet. Default is true.
+  delegation                   = map(list(object({ name = string, actions = list(string) }))) # Configuration delegations on subnet. Default is {}.
+  network_security_group_name  = string       # The Network Security Group name to associate with the subnet
+  network_security_group_rg    = string       # The Network Security Group RG to associate with the subnet. Default is the same RG than the subnet.
+  route_table_name             = string       # The Route Table name to associate with the subnet
+  route_table_rg               = string       # The Route Table RG to associate with the subnet. Default is the same RG than the subnet.
+}))
 EOD
-  type        = map(list(any))
-  default     = {}
+  type = map(object({
+    cidr_list                    = list(string)
+    custom_name                  = optional(string)
+    service_endpoints            = optional(list(string), [])
+    private_endpoint_enabled     = optional(bool, true)
+    private_link_service_enabled = optional(bool, true)
+    delegation = optional(map(list(object({
+      name    = string
+      actions = list(string)
+    }))), {})
+    network_security_group_name = optional(string)
+    network_security_group_rg   = optional(string)
+    route_table_name            = optional(string)
+    route_table_rg              = optional(string)
+  }))
 }
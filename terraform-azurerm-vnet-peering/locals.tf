@@ -0,0 +1,4 @@
+locals {
+  local_peering_name  = coalesce(var.custom_local_peering_name, "peer-${var.vnet_name}-to-${var.remote_vnet_name}")
+  remote_peering_name = coalesce(var.custom_remote_peering_name, "peer-${var.remote_vnet_name}-to-${var.vnet_name}")
+}
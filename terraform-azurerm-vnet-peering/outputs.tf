@@ -0,0 +1,19 @@
+output "local_peering_id" {
+  description = "Id of the peering from the local to the remote virtual network"
+  value       = azurerm_virtual_network_peering.local_to_remote.id
+}
+
+output "local_peering_name" {
+  description = "Name of the peering from the local to the remote virtual network"
+  value       = azurerm_virtual_network_peering.local_to_remote.name
+}
+
+output "remote_peering_id" {
+  description = "Id of the peering from the remote to the local virtual network"
+  value       = azurerm_virtual_network_peering.remote_to_local.id
+}
+
+output "remote_peering_name" {
+  description = "Name of the peering from the remote to the local virtual network"
+  value       = azurerm_virtual_network_peering.remote_to_local.name
+}
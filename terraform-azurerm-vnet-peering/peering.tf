@@ -0,0 +1,35 @@
+data "azurerm_virtual_network" "local" {
+  name                = var.vnet_name
+  resource_group_name = var.vnet_resource_group_name
+}
+
+data "azurerm_virtual_network" "remote" {
+  provider = azurerm.remote
+
+  name                = var.remote_vnet_name
+  resource_group_name = var.remote_vnet_resource_group_name
+}
+
+resource "azurerm_virtual_network_peering" "local_to_remote" {
+  name                         = local.local_peering_name
+  resource_group_name          = var.vnet_resource_group_name
+  virtual_network_name         = var.vnet_name
+  remote_virtual_network_id    = data.azurerm_virtual_network.remote.id
+  allow_virtual_network_access = var.allow_virtual_network_access
+  allow_forwarded_traffic      = var.local_peering_options.allow_forwarded_traffic
+  allow_gateway_transit        = var.local_peering_options.allow_gateway_transit
+  use_remote_gateways          = var.local_peering_options.use_remote_gateways
+}
+
+resource "azurerm_virtual_network_peering" "remote_to_local" {
+  provider = azurerm.remote
+
+  name                         = local.remote_peering_name
+  resource_group_name          = var.remote_vnet_resource_group_name
+  virtual_network_name         = var.remote_vnet_name
+  remote_virtual_network_id    = data.azurerm_virtual_network.local.id
+  allow_virtual_network_access = var.allow_virtual_network_access
+  allow_forwarded_traffic      = var.remote_peering_options.allow_forwarded_traffic
+  allow_gateway_transit        = var.remote_peering_options.allow_gateway_transit
+  use_remote_gateways          = var.remote_peering_options.use_remote_gateways
+}
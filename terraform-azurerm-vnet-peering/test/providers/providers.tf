@@ -0,0 +1,17 @@
+# Provider configurations of the module when it is deployed by the tests, copied next to its files. The remote
+# virtual network is in the remote_subscription_id subscription, or the ARM_SUBSCRIPTION_ID one when it is null.
+variable "remote_subscription_id" {
+  description = "Subscription of the remote virtual network"
+  type        = string
+  default     = null
+}
+
+provider "azurerm" {
+  features {}
+}
+
+provider "azurerm" {
+  alias           = "remote"
+  subscription_id = var.remote_subscription_id
+  features {}
+}
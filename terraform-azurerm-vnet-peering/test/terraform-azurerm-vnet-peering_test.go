@@ -0,0 +1,260 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	th "terratest-helpers"
+)
+
+// Global test variables
+var (
+	location                      = "CanadaCentral"
+	setupTerraformDir             = "terraform"
+	providersTerraformDir         = "providers"
+	moduleTerraformDir            = "../"
+	policyDir                     = "../../policies"
+	testModuleDir                 = "module/"
+	testSetupDir                  = "terraform/"
+	testModuleTerraformOptionsDir = "peeringTerraformOptions/"
+	testSetupTerraformOptionsDir  = "setupTerraformOptions/"
+)
+
+type VirtualNetworkPeeringTestData struct {
+	// Variables for the local vnet (eg. the spoke)
+	vNetRgName string
+	vNetCidr   string
+	vNetName   string
+	// Variables for the remote vnet (eg. the hub), in the subscription of the tests when remoteSubscriptionID is empty
+	remoteSubscriptionID string
+	remoteVNetRgName     string
+	remoteVNetCidr       string
+	remoteVNetName       string
+	// Variables for the peerings
+	allowVirtualNetworkAccess bool
+	localPeering              PeeringOptions
+	remotePeering             PeeringOptions
+}
+
+// Options of one direction of the peering
+type PeeringOptions struct {
+	allowForwardedTraffic bool
+	allowGatewayTransit   bool
+	// Needs a gateway in the remote vnet, so it is left disabled by the tests
+	useRemoteGateways bool
+}
+
+func TestVirtualNetworkPeeringWithDefaultConfigs(t *testing.T) {
+	testRootDir := "TestVirtualNetworkPeeringWithDefaultConfigs/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	testData := VirtualNetworkPeeringTestData{
+		vNetRgName:                fmt.Sprintf("rg-peer-unit-test-%s", nameSuffix),
+		remoteVNetRgName:          fmt.Sprintf("rg-peer-hub-unit-test-%s", nameSuffix),
+		vNetCidr:                  "10.0.0.0/16",
+		vNetName:                  fmt.Sprintf("vnet-spoke-unit-test-%s", nameSuffix),
+		remoteVNetCidr:            "10.1.0.0/16",
+		remoteVNetName:            fmt.Sprintf("vnet-hub-unit-test-%s", nameSuffix),
+		allowVirtualNetworkAccess: true,
+	}
+
+	VirtualNetworkPeering(t, testRootDir, nameSuffix, testData)
+}
+
+func TestVirtualNetworkPeeringWithHubOptions(t *testing.T) {
+	testRootDir := "TestVirtualNetworkPeeringWithHubOptions/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	// The spoke accepts the traffic forwarded by the hub (eg. by a firewall), and the hub offers its gateway
+	testData := VirtualNetworkPeeringTestData{
+		vNetRgName:                fmt.Sprintf("rg-peer-unit-test-%s", nameSuffix),
+		remoteVNetRgName:          fmt.Sprintf("rg-peer-hub-unit-test-%s", nameSuffix),
+		vNetCidr:                  "10.2.0.0/16",
+		vNetName:                  fmt.Sprintf("vnet-spoke-unit-test-%s", nameSuffix),
+		remoteVNetCidr:            "10.3.0.0/16",
+		remoteVNetName:            fmt.Sprintf("vnet-hub-unit-test-%s", nameSuffix),
+		allowVirtualNetworkAccess: true,
+		localPeering: PeeringOptions{
+			allowForwardedTraffic: true,
+		},
+		remotePeering: PeeringOptions{
+			allowForwardedTraffic: true,
+			allowGatewayTransit:   true,
+		},
+	}
+
+	VirtualNetworkPeering(t, testRootDir, nameSuffix, testData)
+}
+
+func TestVirtualNetworkPeeringCrossSubscription(t *testing.T) {
+	testRootDir := "TestVirtualNetworkPeeringCrossSubscription/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	// The hub is in another subscription, which the credentials of the tests must be able to deploy to
+	remoteSubscriptionID := os.Getenv("TERRATEST_REMOTE_SUBSCRIPTION_ID")
+	if remoteSubscriptionID == "" {
+		t.Skip("Set TERRATEST_REMOTE_SUBSCRIPTION_ID to test a peering with a virtual network of another subscription")
+	}
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	testData := VirtualNetworkPeeringTestData{
+		vNetRgName:                fmt.Sprintf("rg-peer-unit-test-%s", nameSuffix),
+		remoteSubscriptionID:      remoteSubscriptionID,
+		remoteVNetRgName:          fmt.Sprintf("rg-peer-hub-unit-test-%s", nameSuffix),
+		vNetCidr:                  "10.4.0.0/16",
+		vNetName:                  fmt.Sprintf("vnet-spoke-unit-test-%s", nameSuffix),
+		remoteVNetCidr:            "10.5.0.0/16",
+		remoteVNetName:            fmt.Sprintf("vnet-hub-unit-test-%s", nameSuffix),
+		allowVirtualNetworkAccess: true,
+	}
+
+	VirtualNetworkPeering(t, testRootDir, nameSuffix, testData)
+}
+
+func VirtualNetworkPeering(t *testing.T, testRootDir string, nameSuffix string, testData VirtualNetworkPeeringTestData) {
+	// At the end of the test, clean up resources.
+	defer th.RunTestStage(t, "teardown_"+testRootDir, func() {
+		TearDown(t, testRootDir)
+	})
+
+	// Lint the module copied into the testRootDir before deploying anything (the setup stage removes the copy, and the
+	// deploy stage copies the module again)
+	th.RunTestStage(t, "lint_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+		th.LintModule(t, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+	})
+
+	th.RunTestStage(t, "setup_"+testRootDir, func() {
+		// If state files exist, clean up resources
+		TearDown(t, testRootDir)
+		th.CopyTerraformFolder(t, setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
+		ts.SaveString(t, testRootDir, "nameSuffix", nameSuffix)
+
+		setupConfig := map[string]interface{}{
+			"location":                   location,
+			"resource_group_name":        testData.vNetRgName,
+			"vnet_name":                  testData.vNetName,
+			"address_space":              []string{testData.vNetCidr},
+			"remote_resource_group_name": testData.remoteVNetRgName,
+			"remote_vnet_name":           testData.remoteVNetName,
+			"remote_address_space":       []string{testData.remoteVNetCidr},
+		}
+		if testData.remoteSubscriptionID != "" {
+			setupConfig["remote_subscription_id"] = testData.remoteSubscriptionID
+		}
+		setupTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir), map[string]interface{}{
+			"config": setupConfig,
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir, setupTerraformOptions)
+		th.InitAndApply(t, setupTerraformOptions)
+	})
+
+	th.RunTestStage(t, "deploy_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+		// The module is passed its azurerm and azurerm.remote providers by its caller, so they are configured next to
+		// the copied module files
+		th.CopyTerraformFolder(t, providersTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+
+		peeringVars := map[string]interface{}{
+			"vnet_resource_group_name":        testData.vNetRgName,
+			"vnet_name":                       testData.vNetName,
+			"remote_vnet_resource_group_name": testData.remoteVNetRgName,
+			"remote_vnet_name":                testData.remoteVNetName,
+			"allow_virtual_network_access":    testData.allowVirtualNetworkAccess,
+			"local_peering_options":           PeeringOptionsVariable(testData.localPeering),
+			"remote_peering_options":          PeeringOptionsVariable(testData.remotePeering),
+		}
+		if testData.remoteSubscriptionID != "" {
+			peeringVars["remote_subscription_id"] = testData.remoteSubscriptionID
+		}
+		peeringTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir), peeringVars)
+
+		th.SaveTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir, peeringTerraformOptions)
+
+		// Check the module plan against the security policies before applying it
+		th.RunTestStage(t, "policy_"+testRootDir, func() {
+			th.CheckPlanPolicies(t, peeringTerraformOptions, policyDir, th.PolicyOptions{})
+		})
+		th.InitAndApply(t, peeringTerraformOptions)
+	})
+
+	th.RunTestStage(t, "validate_"+testRootDir, func() {
+		peeringTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir)
+		setupTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir)
+		vNetID := terraform.Output(t, setupTerraformOptions, "vnet_id")
+		remoteVNetID := terraform.Output(t, setupTerraformOptions, "remote_vnet_id")
+
+		// Ensure that both directions of the peering exist, are connected and have the expected options
+		ValidatePeering(t, terraform.Output(t, peeringTerraformOptions, "local_peering_id"), th.VirtualNetworkPeeringExpectation{
+			Name:                   fmt.Sprintf("peer-%s-to-%s", testData.vNetName, testData.remoteVNetName),
+			RemoteVirtualNetworkID: remoteVNetID,
+		}, testData.allowVirtualNetworkAccess, testData.localPeering)
+		ValidatePeering(t, terraform.Output(t, peeringTerraformOptions, "remote_peering_id"), th.VirtualNetworkPeeringExpectation{
+			Name:                   fmt.Sprintf("peer-%s-to-%s", testData.remoteVNetName, testData.vNetName),
+			RemoteVirtualNetworkID: vNetID,
+		}, testData.allowVirtualNetworkAccess, testData.remotePeering)
+	})
+}
+
+// Returns the peering options variable of the module
+func PeeringOptionsVariable(options PeeringOptions) map[string]interface{} {
+	return map[string]interface{}{
+		"allow_forwarded_traffic": options.allowForwardedTraffic,
+		"allow_gateway_transit":   options.allowGatewayTransit,
+		"use_remote_gateways":     options.useRemoteGateways,
+	}
+}
+
+// Waits for the peering to be connected, as a peering stays initiated until its other direction is created, and
+// ensures that it matches the expectation and options
+func ValidatePeering(t *testing.T, peeringID string, expectation th.VirtualNetworkPeeringExpectation, allowVirtualNetworkAccess bool, options PeeringOptions) {
+	th.EventuallyProperty(t, peeringID, "properties.peeringState", "Connected")
+
+	expectation.PeeringState = "Connected"
+	expectation.AllowVirtualNetworkAccess = &allowVirtualNetworkAccess
+	expectation.AllowForwardedTraffic = &options.allowForwardedTraffic
+	expectation.AllowGatewayTransit = &options.allowGatewayTransit
+	expectation.UseRemoteGateways = &options.useRemoteGateways
+	th.AssertExpectation(t, th.GetAzureResource(t, peeringID), expectation)
+}
+
+// Destroys the peerings and setup resources and removes the testRootDir
+func TearDown(t *testing.T, testRootDir string) {
+	th.TearDown(t, testRootDir, testModuleTerraformOptionsDir, testSetupTerraformOptionsDir)
+}
@@ -0,0 +1,50 @@
+terraform {
+  backend "local" {}
+}
+
+provider "azurerm" {
+  features {}
+}
+
+# The remote virtual network is in the remote_subscription_id subscription (eg. the hub's), the current one by default
+provider "azurerm" {
+  alias           = "remote"
+  subscription_id = lookup(var.config, "remote_subscription_id", null)
+  features {}
+}
+
+resource "azurerm_resource_group" "rg" {
+  name     = lookup(var.config, "resource_group_name")
+  location = lookup(var.config, "location")
+}
+
+resource "azurerm_resource_group" "remote_rg" {
+  provider = azurerm.remote
+
+  name     = lookup(var.config, "remote_resource_group_name")
+  location = lookup(var.config, "location")
+}
+
+resource "azurerm_virtual_network" "vnet" {
+  name                = lookup(var.config, "vnet_name")
+  location            = lookup(var.config, "location")
+  address_space       = lookup(var.config, "address_space")
+  resource_group_name = azurerm_resource_group.rg.name
+}
+
+resource "azurerm_virtual_network" "remote_vnet" {
+  provider = azurerm.remote
+
+  name                = lookup(var.config, "remote_vnet_name")
+  location            = lookup(var.config, "location")
+  address_space       = lookup(var.config, "remote_address_space")
+  resource_group_name = azurerm_resource_group.remote_rg.name
+}
+
+output "vnet_id" {
+  value = azurerm_virtual_network.vnet.id
+}
+
+output "remote_vnet_id" {
+  value = azurerm_virtual_network.remote_vnet.id
+}
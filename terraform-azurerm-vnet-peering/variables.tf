@@ -0,0 +1,71 @@
+variable "vnet_resource_group_name" {
+  description = "Resource group name of the local virtual network (eg. the spoke)"
+  type        = string
+}
+
+variable "vnet_name" {
+  description = "Name of the local virtual network (eg. the spoke)"
+  type        = string
+}
+
+variable "remote_vnet_resource_group_name" {
+  description = "Resource group name of the remote virtual network (eg. the hub)"
+  type        = string
+}
+
+variable "remote_vnet_name" {
+  description = "Name of the remote virtual network (eg. the hub)"
+  type        = string
+}
+
+variable "allow_virtual_network_access" {
+  description = "Allow the VMs of each virtual network to access the VMs of the other one. Defaults to true."
+  type        = bool
+  default     = true
+}
+
+variable "local_peering_options" {
+  description = <<EOD
+Options of the peering from the local to the remote virtual network
+object({
+  allow_forwarded_traffic = bool # Allow the traffic forwarded by the remote virtual network. Default is false.
+  allow_gateway_transit   = bool # Allow the remote virtual network to use the gateway of the local one. Default is false.
+  use_remote_gateways     = bool # Use the gateway of the remote virtual network (which must allow gateway transit). Default is false.
+})
+EOD
+  type = object({
+    allow_forwarded_traffic = optional(bool, false)
+    allow_gateway_transit   = optional(bool, false)
+    use_remote_gateways     = optional(bool, false)
+  })
+  default = {}
+}
+
+variable "remote_peering_options" {
+  description = <<EOD
+Options of the peering from the remote to the local virtual network
+object({
+  allow_forwarded_traffic = bool # Allow the traffic forwarded by the local virtual network. Default is false.
+  allow_gateway_transit   = bool # Allow the local virtual network to use the gateway of the remote one (eg. the hub's VPN gateway). Default is false.
+  use_remote_gateways     = bool # Use the gateway of the local virtual network (which must allow gateway transit). Default is false.
+})
+EOD
+  type = object({
+    allow_forwarded_traffic = optional(bool, false)
+    allow_gateway_transit   = optional(bool, false)
+    use_remote_gateways     = optional(bool, false)
+  })
+  default = {}
+}
+
+variable "custom_local_peering_name" {
+  description = "Optional custom name of the peering from the local to the remote virtual network. Default is peer-<vnet_name>-to-<remote_vnet_name>."
+  type        = string
+  default     = ""
+}
+
+variable "custom_remote_peering_name" {
+  description = "Optional custom name of the peering from the remote to the local virtual network. Default is peer-<remote_vnet_name>-to-<vnet_name>."
+  type        = string
+  default     = ""
+}
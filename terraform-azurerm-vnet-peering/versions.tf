@@ -0,0 +1,12 @@
+terraform {
+  required_version = ">= 1.3.2"
+  required_providers {
+    # The remote virtual network and its peering are managed with azurerm.remote, so the remote virtual network can be
+    # in another subscription (eg. the hub's)
+    azurerm = {
+      source                = "hashicorp/azurerm"
+      version               = ">= 3.30"
+      configuration_aliases = [azurerm.remote]
+    }
+  }
+}
@@ -6,4 +6,19 @@ output "vnet_name" {
 output "vnet_cidr" {
   value       = azurerm_virtual_network.vnet.address_space
   description = "Vnet CIDR"
+}
+
+output "vnet_id" {
+  value       = azurerm_virtual_network.vnet.id
+  description = "Vnet ID"
+}
+
+output "vnet_guid" {
+  value       = azurerm_virtual_network.vnet.guid
+  description = "Vnet GUID"
+}
+
+output "vnet_diagnostic_setting_id" {
+  value       = one(azurerm_monitor_diagnostic_setting.diag-vnet[*].id)
+  description = "ID of the vnet diagnostic setting. Null if log_analytics_id is not set."
 }
\ No newline at end of file
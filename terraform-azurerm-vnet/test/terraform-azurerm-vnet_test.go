@@ -2,178 +2,419 @@ package test
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 
-	"github.com/gruntwork-io/terratest/modules/azure"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	ts "github.com/gruntwork-io/terratest/modules/test-structure"
-	"github.com/stretchr/testify/assert"
-	"github.com/thanhpk/randstr"
+	th "terratest-helpers"
 )
 
 // Global test variables
 var (
-	subscriptionID     = "68dfddb8-d4ed-4f1c-bd08-78674c7088f7"
-	location           = "CanadaCentral"
-	clientName         = "client"
-	environment        = "test"
-	stack              = "stack"
-	suffixLength       = 8
-	setupTerraformDir  = "terraform"
-	moduleTerraformDir = "../"
-	ddosPlanID         = "/subscriptions/fdc723e3-0c24-4b90-bb60-55bf4c819bf2/resourceGroups/rg-nmlgc-rz-security/providers/Microsoft.Network/ddosProtectionPlans/ddos-nmlgc-protection-plan"
-	laWorkspaceID      = "/subscriptions/fdc723e3-0c24-4b90-bb60-55bf4c819bf2/resourceGroups/rg-nmlgc-rz-security/providers/Microsoft.OperationalInsights/workspaces/workspace-nmlgc-all"
+	subscriptionID                = "68dfddb8-d4ed-4f1c-bd08-78674c7088f7"
+	location                      = "CanadaCentral"
+	clientName                    = "client"
+	environment                   = "test"
+	stack                         = "stack"
+	setupTerraformDir             = "terraform"
+	moduleTerraformDir            = "../"
+	policyDir                     = "../../policies"
+	testModuleDir                 = "module/"
+	testSetupDir                  = "terraform/"
+	testModuleTerraformOptionsDir = "virtualNetworkTerraformOptions/"
+	testSetupTerraformOptionsDir  = "setupTerraformOptions/"
+	ddosPlanID                    = "/subscriptions/fdc723e3-0c24-4b90-bb60-55bf4c819bf2/resourceGroups/rg-nmlgc-rz-security/providers/Microsoft.Network/ddosProtectionPlans/ddos-nmlgc-protection-plan"
+	laWorkspaceID                 = "/subscriptions/fdc723e3-0c24-4b90-bb60-55bf4c819bf2/resourceGroups/rg-nmlgc-rz-security/providers/Microsoft.OperationalInsights/workspaces/workspace-nmlgc-all"
 )
 
+// A struct containing any variables needed for implementing a test
+type VirtualNetworkTestData struct {
+	vNetRgName string
+	vNetCidr   []string
+	vNetName   string
+	// DDoS protection and diagnostics are disabled when empty
+	vNetDDOSID        string `sensitive:"true"`
+	vNetLAWorkspaceID string `sensitive:"true"`
+	// Diagnostic categories, left to the module defaults when nil
+	vNetLogCategories    []string
+	vNetMetricCategories []string
+	// Optional variables, left unset when empty
+	vNetDNSServers           []string
+	vNetBGPCommunity         string
+	vNetFlowTimeoutInMinutes int
+	vNetTags                 map[string]string
+	// Region of the resource group and virtual network, the global location when empty
+	vNetLocation string
+	// Edge Zones are not available in every region (eg. CanadaCentral), see TestVirtualNetworkInEdgeZone
+	vNetEdgeZone string
+	// Names and CIDRs of the inline subnets
+	vNetSubnets map[string]string
+}
+
 func TestVirtualNetworkSingleCIDR(t *testing.T) {
+	testRootDir := "TestVirtualNetworkSingleCIDR/"
+
 	// Uncomment any of the following lines to skip that test stage
-	// os.Setenv("SKIP_setup", "true")
-	// os.Setenv("SKIP_vnetApply", "true")
-	// os.Setenv("SKIP_validate", "true")
-	// os.Setenv("SKIP_teardown", "true")
-
-	// Generate test variables
-	nameSuffix := strings.ToLower(randstr.String(suffixLength))
-	vNetRgName := fmt.Sprintf("rg-vnet-unit-test-%s", nameSuffix)
-	// Variables for vnet test
-	vNetCidr := []string{"10.0.0.0/16"}
-	vNetName := fmt.Sprintf("vnet-stack-client-test-%s", nameSuffix)
-	vNetDDOSID := ddosPlanID
-	vNetLAWorkspaceID := laWorkspaceID
-	// Terraform options for setup resources
-	setupTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: setupTerraformDir,
-		Vars: map[string]interface{}{
-			"config": map[string]interface{}{
-				"location":            location,
-				"resource_group_name": vNetRgName,
-			},
-		},
-	})
-	// Terraform options for vnet resources
-	vnetTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: moduleTerraformDir,
-		Vars: map[string]interface{}{
-			"location":            location,
-			"ddos_id":             vNetDDOSID,
-			"log_analytics_id":    vNetLAWorkspaceID,
-			"vnet_cidr":           vNetCidr,
-			"resource_group_name": vNetRgName,
-			"name_suffix":         nameSuffix,
-			"client_name":         clientName,
-			"environment":         environment,
-			"stack":               stack,
-		},
-	})
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
 
-	// At the end of the test, clean up resources.
-	defer ts.RunTestStage(t, "teardown", func() {
-		terraform.Destroy(t, vnetTerraformOptions)
-		terraform.Destroy(t, setupTerraformOptions)
-	})
+	t.Parallel() // Remove to test serially
 
-	ts.RunTestStage(t, "setup", func() {
-		terraform.InitAndApply(t, setupTerraformOptions)
-	})
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
 
-	ts.RunTestStage(t, "vnetApply", func() {
-		terraform.InitAndApply(t, vnetTerraformOptions)
-	})
+	testData := VirtualNetworkTestData{
+		vNetRgName:        fmt.Sprintf("rg-vnet-unit-test-%s", nameSuffix),
+		vNetCidr:          []string{"10.0.0.0/16"},
+		vNetName:          fmt.Sprintf("vnet-stack-client-test-%s", nameSuffix),
+		vNetDDOSID:        ddosPlanID,
+		vNetLAWorkspaceID: laWorkspaceID,
+	}
+
+	VirtualNetwork(t, testRootDir, nameSuffix, testData)
+}
 
-	ts.RunTestStage(t, "validate", func() {
-		// Assert that the virtual network exists
-		assert.True(t, azure.VirtualNetworkExists(t, vNetName, vNetRgName, subscriptionID))
+func TestVirtualNetworkMultipleCIDR(t *testing.T) {
+	testRootDir := "TestVirtualNetworkMultipleCIDR/"
 
-		// Get the deployed virtual network properties
-		deployedVNet, err := azure.GetVirtualNetworkE(vNetName, vNetRgName, subscriptionID)
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
 
-		// Basic assertions to ensure no errors, and proper attributes are correct
-		assert.Nil(t, err)
-		assert.NotNil(t, *deployedVNet.ID)
-		assert.Equal(t, vNetName, *deployedVNet.Name)
-		assert.Equal(t, strings.ToLower(location), strings.ToLower(*deployedVNet.Location))
+	t.Parallel() // Remove to test serially
 
-		// Virtual network address configs
-		deployedVNetAddrConfs := deployedVNet.VirtualNetworkPropertiesFormat
-		assert.Equal(t, vNetCidr, *deployedVNetAddrConfs.AddressSpace.AddressPrefixes)
-		assert.True(t, *deployedVNetAddrConfs.EnableDdosProtection)
-		assert.Equal(t, vNetDDOSID, *deployedVNetAddrConfs.DdosProtectionPlan.ID)
-	})
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	testData := VirtualNetworkTestData{
+		vNetRgName:        fmt.Sprintf("rg-vnet-unit-test-%s", nameSuffix),
+		vNetCidr:          []string{"10.5.0.0/24", "10.6.0.0/24"},
+		vNetName:          fmt.Sprintf("vnet-stack-client-test-%s", nameSuffix),
+		vNetDDOSID:        ddosPlanID,
+		vNetLAWorkspaceID: laWorkspaceID,
+	}
+
+	VirtualNetwork(t, testRootDir, nameSuffix, testData)
 }
 
-func TestVirtualNetworkMultipleCIDR(t *testing.T) {
+func TestVirtualNetworkWithOptionalConfigs(t *testing.T) {
+	testRootDir := "TestVirtualNetworkWithOptionalConfigs/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	testData := VirtualNetworkTestData{
+		vNetRgName:               fmt.Sprintf("rg-vnet-unit-test-%s", nameSuffix),
+		vNetCidr:                 []string{"10.7.0.0/16"},
+		vNetName:                 fmt.Sprintf("vnet-stack-client-test-%s", nameSuffix),
+		vNetDDOSID:               ddosPlanID,
+		vNetLAWorkspaceID:        laWorkspaceID,
+		vNetDNSServers:           []string{"10.7.0.4", "10.7.0.5"},
+		vNetBGPCommunity:         "12076:20000",
+		vNetFlowTimeoutInMinutes: 10,
+		vNetTags:                 map[string]string{"environment": environment, "stack": stack},
+		vNetSubnets:              map[string]string{fmt.Sprintf("snet-inline-%s", nameSuffix): "10.7.0.0/24"},
+	}
+
+	VirtualNetwork(t, testRootDir, nameSuffix, testData)
+}
+
+func TestVirtualNetworkWithoutDDoS(t *testing.T) {
+	testRootDir := "TestVirtualNetworkWithoutDDoS/"
+
 	// Uncomment any of the following lines to skip that test stage
-	// os.Setenv("SKIP_setup", "true")
-	// os.Setenv("SKIP_vnetApply", "true")
-	// os.Setenv("SKIP_validate", "true")
-	// os.Setenv("SKIP_teardown", "true")
-
-	// Generate test variables
-	nameSuffix := strings.ToLower(randstr.String(suffixLength))
-	vNetRgName := fmt.Sprintf("rg-vnet-unit-test-%s", nameSuffix)
-	// Variables for vnet test
-	vNetCidr := []string{"10.5.0.0/24", "10.6.0.0/24"}
-	vNetName := fmt.Sprintf("vnet-stack-client-test-%s", nameSuffix)
-	vNetDDOSID := ddosPlanID
-	vNetLAWorkspaceID := laWorkspaceID
-	// Terraform options for setup resources
-	setupTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: setupTerraformDir,
-		Vars: map[string]interface{}{
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	// DDoS protection is disabled (eg. in a dev environment), and only the logs are sent to the workspace
+	testData := VirtualNetworkTestData{
+		vNetRgName:           fmt.Sprintf("rg-vnet-unit-test-%s", nameSuffix),
+		vNetCidr:             []string{"10.8.0.0/16"},
+		vNetName:             fmt.Sprintf("vnet-stack-client-test-%s", nameSuffix),
+		vNetLAWorkspaceID:    laWorkspaceID,
+		vNetLogCategories:    []string{"VMProtectionAlerts"},
+		vNetMetricCategories: []string{},
+	}
+
+	VirtualNetwork(t, testRootDir, nameSuffix, testData)
+}
+
+func TestVirtualNetworkWithoutDiagnostics(t *testing.T) {
+	testRootDir := "TestVirtualNetworkWithoutDiagnostics/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	// No diagnostic setting is created without a workspace
+	testData := VirtualNetworkTestData{
+		vNetRgName: fmt.Sprintf("rg-vnet-unit-test-%s", nameSuffix),
+		vNetCidr:   []string{"10.9.0.0/16"},
+		vNetName:   fmt.Sprintf("vnet-stack-client-test-%s", nameSuffix),
+		vNetDDOSID: ddosPlanID,
+	}
+
+	VirtualNetwork(t, testRootDir, nameSuffix, testData)
+}
+
+func TestVirtualNetworkWithoutDDoSAndDiagnostics(t *testing.T) {
+	testRootDir := "TestVirtualNetworkWithoutDDoSAndDiagnostics/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	// Neither a DDoS protection plan nor a workspace is needed (eg. in a dev environment)
+	testData := VirtualNetworkTestData{
+		vNetRgName: fmt.Sprintf("rg-vnet-unit-test-%s", nameSuffix),
+		vNetCidr:   []string{"10.10.0.0/16"},
+		vNetName:   fmt.Sprintf("vnet-stack-client-test-%s", nameSuffix),
+	}
+
+	VirtualNetwork(t, testRootDir, nameSuffix, testData)
+}
+
+func TestVirtualNetworkInEdgeZone(t *testing.T) {
+	testRootDir := "TestVirtualNetworkInEdgeZone/"
+
+	// Uncomment any of the following lines to skip that test stage
+	// os.Setenv("SKIP_lint_" + testRootDir, "true")
+	// os.Setenv("SKIP_setup_" + testRootDir, "true")
+	// os.Setenv("SKIP_deploy_" + testRootDir, "true")
+	// os.Setenv("SKIP_policy_" + testRootDir, "true")
+	// os.Setenv("SKIP_validate_" + testRootDir, "true")
+	// os.Setenv("SKIP_teardown_" + testRootDir, "true")
+
+	// Edge Zones are only available in some regions, to the subscriptions registered for them
+	edgeZone, edgeZoneLocation := os.Getenv("TERRATEST_EDGE_ZONE"), os.Getenv("TERRATEST_EDGE_ZONE_LOCATION")
+	if edgeZone == "" || edgeZoneLocation == "" {
+		t.Skip("Set TERRATEST_EDGE_ZONE and TERRATEST_EDGE_ZONE_LOCATION (eg. microsoftlosangeles1 and WestUS) to test a virtual network in an Edge Zone")
+	}
+
+	t.Parallel() // Remove to test serially
+
+	nameSuffix := th.GetNameSuffix(t, testRootDir)
+
+	testData := VirtualNetworkTestData{
+		vNetRgName:   fmt.Sprintf("rg-vnet-unit-test-%s", nameSuffix),
+		vNetCidr:     []string{"10.15.0.0/16"},
+		vNetName:     fmt.Sprintf("vnet-stack-client-test-%s", nameSuffix),
+		vNetLocation: edgeZoneLocation,
+		vNetEdgeZone: edgeZone,
+	}
+
+	VirtualNetwork(t, testRootDir, nameSuffix, testData)
+}
+
+func VirtualNetwork(t *testing.T, testRootDir string, nameSuffix string, testData VirtualNetworkTestData) {
+	if testData.vNetLocation == "" {
+		testData.vNetLocation = location
+	}
+	// Mask the sensitive test data in the saved options, logs and reports
+	th.MarkSensitiveFields(testData)
+
+	// At the end of the test, clean up resources.
+	defer th.RunTestStage(t, "teardown_"+testRootDir, func() {
+		TearDown(t, testRootDir)
+	})
+
+	// Lint the module copied into the testRootDir before deploying anything (the setup stage removes the copy, and the
+	// deploy stage copies the module again)
+	th.RunTestStage(t, "lint_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+		th.LintModule(t, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+	})
+
+	th.RunTestStage(t, "setup_"+testRootDir, func() {
+		// If state files exist, clean up resources
+		TearDown(t, testRootDir)
+		th.CopyTerraformFolder(t, setupTerraformDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir))
+		ts.SaveString(t, testRootDir, "nameSuffix", nameSuffix)
+
+		setupTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testSetupDir), map[string]interface{}{
 			"config": map[string]interface{}{
-				"location":            location,
-				"resource_group_name": vNetRgName,
+				"location":            testData.vNetLocation,
+				"resource_group_name": testData.vNetRgName,
 			},
-		},
+		})
+
+		th.SaveTerraformOptions(t, testRootDir, testSetupTerraformOptionsDir, setupTerraformOptions)
+		th.InitAndApply(t, setupTerraformOptions)
 	})
-	// Terraform options for vnet resources
-	vnetTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: moduleTerraformDir,
-		Vars: map[string]interface{}{
-			"location":            location,
-			"ddos_id":             vNetDDOSID,
-			"log_analytics_id":    vNetLAWorkspaceID,
-			"vnet_cidr":           vNetCidr,
-			"resource_group_name": vNetRgName,
+
+	th.RunTestStage(t, "deploy_"+testRootDir, func() {
+		th.CopyTerraformFolder(t, moduleTerraformDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir))
+
+		virtualNetworkVars := map[string]interface{}{
+			"location":            testData.vNetLocation,
+			"vnet_cidr":           testData.vNetCidr,
+			"resource_group_name": testData.vNetRgName,
 			"name_suffix":         nameSuffix,
 			"client_name":         clientName,
 			"environment":         environment,
 			"stack":               stack,
-		},
-	})
+		}
+		// Only set the optional variables given by the test data, so the module defaults are tested otherwise. They are
+		// all set before the options are built, so the sensitive ones are passed as TF_VAR_ environment variables.
+		if testData.vNetDDOSID != "" {
+			virtualNetworkVars["ddos_id"] = testData.vNetDDOSID
+		}
+		if testData.vNetLAWorkspaceID != "" {
+			virtualNetworkVars["log_analytics_id"] = testData.vNetLAWorkspaceID
+		}
+		if testData.vNetLogCategories != nil {
+			virtualNetworkVars["diagnostic_log_categories"] = testData.vNetLogCategories
+		}
+		if testData.vNetMetricCategories != nil {
+			virtualNetworkVars["diagnostic_metric_categories"] = testData.vNetMetricCategories
+		}
+		if len(testData.vNetDNSServers) > 0 {
+			virtualNetworkVars["dns_servers"] = testData.vNetDNSServers
+		}
+		if testData.vNetBGPCommunity != "" {
+			virtualNetworkVars["bgp_community"] = testData.vNetBGPCommunity
+		}
+		if testData.vNetFlowTimeoutInMinutes != 0 {
+			virtualNetworkVars["flow_timeout_in_minutes"] = testData.vNetFlowTimeoutInMinutes
+		}
+		if len(testData.vNetTags) > 0 {
+			virtualNetworkVars["tags"] = testData.vNetTags
+		}
+		if testData.vNetEdgeZone != "" {
+			virtualNetworkVars["edge_zone"] = testData.vNetEdgeZone
+		}
+		if len(testData.vNetSubnets) > 0 {
+			subnets := []map[string]interface{}{}
+			for name, cidr := range testData.vNetSubnets {
+				subnets = append(subnets, map[string]interface{}{"name": name, "address_prefix": cidr})
+			}
+			virtualNetworkVars["subnets"] = subnets
+		}
 
-	// At the end of the test, clean up resources.
-	defer ts.RunTestStage(t, "teardown", func() {
-		terraform.Destroy(t, vnetTerraformOptions)
-		terraform.Destroy(t, setupTerraformOptions)
-	})
+		virtualNetworkTerraformOptions := th.NewTerraformOptions(t, testRootDir, fmt.Sprintf("%s%s", testRootDir, testModuleDir), virtualNetworkVars)
 
-	ts.RunTestStage(t, "setup", func() {
-		terraform.InitAndApply(t, setupTerraformOptions)
-	})
+		th.SaveTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir, virtualNetworkTerraformOptions)
 
-	ts.RunTestStage(t, "vnetApply", func() {
-		terraform.InitAndApply(t, vnetTerraformOptions)
+		// Check the module plan against the security policies before applying it
+		th.RunTestStage(t, "policy_"+testRootDir, func() {
+			policyOptions := th.PolicyOptions{}
+			if testData.vNetDDOSID == "" {
+				// The virtual network is deployed without DDoS protection on purpose
+				policyOptions.Exceptions = map[string][]string{"vnet_ddos": {"azurerm_virtual_network.vnet"}}
+			}
+			th.CheckPlanPolicies(t, virtualNetworkTerraformOptions, policyDir, policyOptions)
+		})
+		th.InitAndApply(t, virtualNetworkTerraformOptions)
 	})
 
-	ts.RunTestStage(t, "validate", func() {
-		// Assert that the virtual network exists
-		assert.True(t, azure.VirtualNetworkExists(t, vNetName, vNetRgName, subscriptionID))
+	th.RunTestStage(t, "validate_"+testRootDir, func() {
+		virtualNetworkTerraformOptions := th.LoadTerraformOptions(t, testRootDir, testModuleTerraformOptionsDir)
+		vNetID := terraform.Output(t, virtualNetworkTerraformOptions, "vnet_id")
+		// Ensure that the vnet ID output by the module is the ID of the expected virtual network
+		th.Assert(t).True(strings.EqualFold(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s", subscriptionID, testData.vNetRgName, testData.vNetName), vNetID))
+
+		// Get the deployed virtual network properties, waiting for the virtual network to exist
+		deployedVNet := th.GetAzureResource(t, vNetID)
 
-		// Get the deployed virtual network properties
-		deployedVNet, err := azure.GetVirtualNetworkE(vNetName, vNetRgName, subscriptionID)
+		// Ensure that the name, location, address configs, DDoS protection and optional configs are correct
+		inlineSubnetNames := []string{}
+		for name := range testData.vNetSubnets {
+			inlineSubnetNames = append(inlineSubnetNames, name)
+		}
+		ddosProtectionEnabled := testData.vNetDDOSID != ""
+		th.AssertExpectation(t, deployedVNet, th.VirtualNetworkExpectation{
+			Name:                 testData.vNetName,
+			Location:             testData.vNetLocation,
+			AddressSpace:         testData.vNetCidr,
+			DNSServers:           testData.vNetDNSServers,
+			DDoSProtectionPlanID: testData.vNetDDOSID,
+			EnableDDoSProtection: &ddosProtectionEnabled,
+			BGPCommunity:         testData.vNetBGPCommunity,
+			FlowTimeoutInMinutes: testData.vNetFlowTimeoutInMinutes,
+			Tags:                 testData.vNetTags,
+			EdgeZone:             testData.vNetEdgeZone,
+			Subnets:              inlineSubnetNames,
+		})
 
-		// Basic assertions to ensure no errors, and proper attributes are correct
-		assert.Nil(t, err)
-		assert.NotNil(t, *deployedVNet.ID)
-		assert.Equal(t, vNetName, *deployedVNet.Name)
-		assert.Equal(t, strings.ToLower(location), strings.ToLower(*deployedVNet.Location))
+		// Ensure that the inline subnets have the correct address space
+		for name, cidr := range testData.vNetSubnets {
+			th.AssertProperty(t, th.GetAzureResource(t, fmt.Sprintf("%s/subnets/%s", vNetID, name)), "properties.addressPrefix", cidr)
+		}
 
-		// Virtual network address configs
-		deployedVNetAddrConfs := deployedVNet.VirtualNetworkPropertiesFormat
-		assert.Equal(t, vNetCidr, *deployedVNetAddrConfs.AddressSpace.AddressPrefixes)
-		assert.True(t, *deployedVNetAddrConfs.EnableDdosProtection)
-		assert.Equal(t, vNetDDOSID, *deployedVNetAddrConfs.DdosProtectionPlan.ID)
+		// Ensure that the vnet GUID output by the module is the deployed one
+		th.AssertProperty(t, deployedVNet, "properties.resourceGuid", terraform.Output(t, virtualNetworkTerraformOptions, "vnet_guid"))
+
+		// Ensure that no diagnostic setting is created without a workspace
+		diagnosticSettings := th.GetDiagnosticSettings(t, vNetID)
+		if testData.vNetLAWorkspaceID == "" {
+			th.Assert(t).Empty(diagnosticSettings)
+			return
+		}
+
+		// Ensure that the diagnostic setting output by the module targets the virtual network
+		th.Assert(t).Equal(fmt.Sprintf("%s|diag-%s", vNetID, testData.vNetName), terraform.Output(t, virtualNetworkTerraformOptions, "vnet_diagnostic_setting_id"))
+
+		// Ensure that the diagnostic setting sends the expected categories to the workspace
+		logCategories, metricCategories := testData.vNetLogCategories, testData.vNetMetricCategories
+		if logCategories == nil {
+			logCategories = []string{"VMProtectionAlerts"}
+		}
+		if metricCategories == nil {
+			metricCategories = []string{"AllMetrics"}
+		}
+		if th.Assert(t).Len(diagnosticSettings, 1) {
+			th.AssertExpectation(t, diagnosticSettings[0], th.DiagnosticSettingExpectation{
+				Name:             fmt.Sprintf("diag-%s", testData.vNetName),
+				WorkspaceID:      testData.vNetLAWorkspaceID,
+				LogCategories:    logCategories,
+				MetricCategories: metricCategories,
+			})
+		}
 	})
 }
+
+// Destroys the virtual network and setup resources and removes the testRootDir
+func TearDown(t *testing.T, testRootDir string) {
+	th.TearDown(t, testRootDir, testModuleTerraformOptionsDir, testSetupTerraformOptionsDir)
+}
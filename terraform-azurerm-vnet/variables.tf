@@ -7,12 +7,26 @@ variable "location" {
 
 variable "ddos_id" {
     type = string
-    description = "Distributed denial-of-service plan ID. The plan is located in NMLGC-Core subscription."
+    description = "Distributed denial-of-service plan ID. The plan is located in NMLGC-Core subscription. DDoS protection is disabled if not set."
+    default = null
 }
 
 variable "log_analytics_id" {
     type = string
-    description = "Log Analytics workspace ID. The plan is located in NMLGC-Core subscription."
+    description = "Log Analytics workspace ID. The plan is located in NMLGC-Core subscription. No diagnostic setting is created if not set."
+    default = null
+}
+
+variable "diagnostic_log_categories" {
+  type        = list(string)
+  description = "Categories of the logs sent to the Log Analytics workspace (at least one log or metric category is required when log_analytics_id is set)"
+  default     = ["VMProtectionAlerts"]
+}
+
+variable "diagnostic_metric_categories" {
+  type        = list(string)
+  description = "Categories of the metrics sent to the Log Analytics workspace (at least one log or metric category is required when log_analytics_id is set)"
+  default     = ["AllMetrics"]
 }
 
 # Networking configs
@@ -25,4 +39,51 @@ variable "resource_group_name" {
 variable "vnet_cidr" {
   type        = list(string)
   description = "The CIDR block definition of the vnet"
+}
+
+variable "dns_servers" {
+  type        = list(string)
+  description = "List of IP addresses of the DNS servers of the vnet. Defaults to the Azure-provided DNS."
+  default     = []
+}
+
+variable "bgp_community" {
+  type        = string
+  description = "Optional BGP community attribute of the vnet, as the AS number and community value (eg. 12076:20000)"
+  default     = null
+}
+
+variable "flow_timeout_in_minutes" {
+  type        = number
+  description = "Optional flow timeout in minutes of the vnet, between 4 and 30"
+  default     = null
+
+  validation {
+    condition     = var.flow_timeout_in_minutes == null ? true : var.flow_timeout_in_minutes >= 4 && var.flow_timeout_in_minutes <= 30
+    error_message = "The flow timeout must be between 4 and 30 minutes."
+  }
+}
+
+variable "edge_zone" {
+  type        = string
+  description = "Optional Edge Zone to create the vnet in"
+  default     = null
+}
+
+variable "subnets" {
+  type = list(object({
+    name           = string
+    address_prefix = string
+    security_group = optional(string)
+  }))
+  description = "Optional subnets declared inline in the vnet. Do not use with subnets managed by azurerm_subnet resources (eg. the subnet module), as the vnet would remove them."
+  default     = []
+}
+
+# Tags
+
+variable "tags" {
+  type        = map(string)
+  description = "Tags to add to the vnet"
+  default     = {}
 }
\ No newline at end of file
@@ -3,31 +3,56 @@ resource "azurerm_virtual_network" "vnet" {
   location            = var.location
   resource_group_name = var.resource_group_name
   address_space       = var.vnet_cidr
+  dns_servers         = var.dns_servers
+  bgp_community       = var.bgp_community
+  edge_zone           = var.edge_zone
+  tags                = var.tags
+
+  flow_timeout_in_minutes = var.flow_timeout_in_minutes
+
+  dynamic "subnet" {
+    for_each = var.subnets
+    content {
+      name           = subnet.value.name
+      address_prefix = subnet.value.address_prefix
+      security_group = subnet.value.security_group
+    }
+  }
   
-  ddos_protection_plan {
-    id     = var.ddos_id
-    enable = "true"
-  } 
+  dynamic "ddos_protection_plan" {
+    for_each = var.ddos_id == null ? [] : [var.ddos_id]
+    content {
+      id     = ddos_protection_plan.value
+      enable = "true"
+    }
+  }
 }
 
 resource "azurerm_monitor_diagnostic_setting" "diag-vnet" {
+  count = var.log_analytics_id == null ? 0 : 1
+
   name               = "diag-${local.vnet_name}"
   target_resource_id = azurerm_virtual_network.vnet.id
   log_analytics_workspace_id = var.log_analytics_id
 
-  log {
-    category = "VMProtectionAlerts"
-    
-    retention_policy {
-      enabled = "false"
+  lifecycle {
+    precondition {
+      condition     = length(var.diagnostic_log_categories) + length(var.diagnostic_metric_categories) > 0
+      error_message = "At least one diagnostic log or metric category must be given when log_analytics_id is set."
     }
   }
 
-  metric {
-    category = "AllMetrics"
+  dynamic "log" {
+    for_each = toset(var.diagnostic_log_categories)
+    content {
+      category = log.value
+    }
+  }
 
-    retention_policy {
-      enabled = "false"
+  dynamic "metric" {
+    for_each = toset(var.diagnostic_metric_categories)
+    content {
+      category = metric.value
     }
   }
 }
\ No newline at end of file
@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	cp "github.com/otiai10/copy"
+)
+
+// Directory the artifacts of failed tests are saved to (in a <TestName> folder). Defaults to test-artifacts (outside
+// of the testRootDir, which is removed by the teardown stage).
+const ArtifactsDirEnv = "TERRATEST_ARTIFACTS_DIR"
+
+const defaultArtifactsDir = "test-artifacts"
+
+// Azure resources fetched during the validation of the tests currently running, by test
+var fetchedResources sync.Map
+
+// Keeps the resource fetched by the test, to be saved with its artifacts if it fails
+func recordFetchedResource(t *testing.T, resource *AzureResource) {
+	resources, loaded := fetchedResources.LoadOrStore(t, &sync.Map{})
+	if !loaded {
+		t.Cleanup(func() { fetchedResources.Delete(t) })
+	}
+	resources.(*sync.Map).Store(resource.ID, resource.Body)
+}
+
+// Returns the artifacts directory of the test
+func getArtifactsDir(t *testing.T) (string, error) {
+	dir := os.Getenv(ArtifactsDirEnv)
+	if dir == "" {
+		dir = defaultArtifactsDir
+	}
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(t.Name())
+	return filepath.Abs(filepath.Join(dir, name))
+}
+
+// Saves the artifacts of a failed test (before the teardown stage destroys everything) to <artifacts dir>/<TestName>:
+//   - <terraformOptionsDir>/state.json: the terraform show -json output of the state
+//   - <terraformOptionsDir>/options.json: the saved Terraform options
+//   - <terraformOptionsDir>/vars.json: the Vars used
+//   - resources.json: the Azure resources fetched during validation (eg. by GetAzureResource, GetSubnet or
+//     EventuallyProperty), by resource ID
+//   - logs: the stage logs
+//
+// The sensitive values (see MarkSensitive) are masked in every file.
+func SaveArtifacts(t *testing.T, testRootDir string, terraformOptionsDirs ...string) {
+	if err := SaveArtifactsE(t, testRootDir, terraformOptionsDirs...); err != nil {
+		t.Logf("Unable to save the artifacts of %s: %v", t.Name(), err)
+	}
+}
+
+// Saves the artifacts of the test. See SaveArtifacts.
+func SaveArtifactsE(t *testing.T, testRootDir string, terraformOptionsDirs ...string) error {
+	dir, err := getArtifactsDir(t)
+	if err != nil {
+		return err
+	}
+	os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	errs := []string{}
+	for _, terraformOptionsDir := range terraformOptionsDirs {
+		if err := saveTerraformArtifacts(t, testRootDir, terraformOptionsDir, filepath.Join(dir, strings.TrimSuffix(terraformOptionsDir, "/"))); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", terraformOptionsDir, err))
+		}
+	}
+
+	if resources, ok := fetchedResources.Load(t); ok {
+		bodies := map[string]interface{}{}
+		resources.(*sync.Map).Range(func(id, body interface{}) bool {
+			bodies[id.(string)] = body
+			return true
+		})
+		if err := writeJSON(filepath.Join(dir, "resources.json"), redactValue(bodies)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if logs := filepath.Join(testRootDir, logsDir); dirExists(logs) {
+		if err := cp.Copy(logs, filepath.Join(dir, logsDir)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	t.Logf("Saved the artifacts of %s to %s", t.Name(), dir)
+	return nil
+}
+
+// Saves the state, options and Vars of the terraform.Options saved in terraformOptionsDir
+func saveTerraformArtifacts(t *testing.T, testRootDir string, terraformOptionsDir string, dest string) error {
+	if !dirExists(testRootDir + terraformOptionsDir) {
+		return nil
+	}
+	options, err := LoadTerraformOptionsE(t, testRootDir, terraformOptionsDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	redacted := redactTerraformOptions(options)
+	if err := writeJSON(filepath.Join(dest, "options.json"), redacted); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(dest, "vars.json"), redacted.Vars); err != nil {
+		return err
+	}
+
+	state, err := terraform.ShowE(t, options)
+	if err != nil {
+		return fmt.Errorf("unable to show the state of %s: %w", options.TerraformDir, err)
+	}
+	return os.WriteFile(filepath.Join(dest, "state.json"), []byte(RedactString(state)), 0644)
+}
+
+func writeJSON(path string, v interface{}) error {
+	encoded := &bytes.Buffer{}
+	encoder := json.NewEncoder(encoded)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return os.WriteFile(path, encoded.Bytes(), 0644)
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
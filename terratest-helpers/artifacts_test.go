@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveArtifactsE(t *testing.T) {
+	artifactsDir := t.TempDir()
+	t.Setenv(ArtifactsDirEnv, artifactsDir)
+	testRootDir := t.TempDir() + "/"
+
+	binary := filepath.Join(t.TempDir(), "terraform")
+	writeFile(t, binary, "#!/bin/sh\necho '{\"format_version\":\"1.0\"}'\n")
+	require.NoError(t, os.Chmod(binary, 0755))
+	ts.SaveTerraformOptions(t, testRootDir+"moduleTerraformOptions/", &terraform.Options{
+		TerraformBinary: binary,
+		TerraformDir:    testRootDir + "module/",
+		Vars:            map[string]interface{}{"vnet_name": "vnet"},
+		EnvVars:         map[string]string{"ARM_CLIENT_ID": "client", "ARM_CLIENT_SECRET": "s3cret"},
+	})
+	require.NoError(t, os.MkdirAll(testRootDir+"module/", 0755))
+	require.NoError(t, appendLog(stageLogPath(testRootDir, "validate"), "validating\n"))
+	recordFetchedResource(t, newTestResource(t, subnetJSON))
+
+	// The setup options were never saved, so they are skipped
+	require.NoError(t, SaveArtifactsE(t, testRootDir, "moduleTerraformOptions/", "setupTerraformOptions/"))
+
+	dir := filepath.Join(artifactsDir, "TestSaveArtifactsE")
+	state, err := os.ReadFile(filepath.Join(dir, "moduleTerraformOptions", "state.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(state), `{"format_version":"1.0"}`)
+
+	options, err := os.ReadFile(filepath.Join(dir, "moduleTerraformOptions", "options.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(options), `"ARM_CLIENT_ID": "client"`)
+	assert.Contains(t, string(options), `"ARM_CLIENT_SECRET": "<redacted:`)
+	assert.NotContains(t, string(options), "s3cret")
+
+	vars, err := os.ReadFile(filepath.Join(dir, "moduleTerraformOptions", "vars.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"vnet_name": "vnet"}`, string(vars))
+
+	assert.FileExists(t, filepath.Join(dir, "resources.json"))
+	assert.FileExists(t, filepath.Join(dir, logsDir, "validate.log"))
+	assert.NoDirExists(t, filepath.Join(dir, "setupTerraformOptions"))
+}
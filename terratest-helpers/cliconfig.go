@@ -0,0 +1,225 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Providers installed from the local filesystem mirror when TERRATEST_PROVIDER_MIRROR_DIR is set. Every other
+// provider is still installed from its registry.
+var MirroredProviders = []string{"hashicorp/azurerm", "aztfmod/azurecaf"}
+
+// Name of the CLI config file generated in the testRootDir
+const cliConfigFileName = "terraform.rc"
+
+// Points the options at a generated CLI config that installs the MirroredProviders from the local filesystem mirror
+// (when TERRATEST_PROVIDER_MIRROR_DIR is set), failing the test if the mirror is missing a provider version required by
+// the Terraform configuration
+func configureProviderMirror(t *testing.T, testRootDir string, options *terraform.Options) {
+	mirrorDir := os.Getenv(ProviderMirrorDirEnv)
+	if mirrorDir == "" {
+		return
+	}
+
+	hostname := registryHostname(options.TerraformBinary)
+	require.NoError(t, CheckProviderMirrorE(options.TerraformDir, mirrorDir, hostname))
+
+	cliConfigFile, err := WriteCLIConfigE(testRootDir, mirrorDir, hostname)
+	require.NoError(t, err)
+
+	if options.EnvVars == nil {
+		options.EnvVars = map[string]string{}
+	}
+	options.EnvVars["TF_CLI_CONFIG_FILE"] = cliConfigFile
+}
+
+// Returns the registry providers without a hostname are installed from (OpenTofu uses its own registry)
+func registryHostname(terraformBinary string) string {
+	if strings.HasPrefix(filepath.Base(terraformBinary), "tofu") {
+		return "registry.opentofu.org"
+	}
+	return "registry.terraform.io"
+}
+
+// Writes the CLI config installing the mirrored providers from mirrorDir to <testRootDir>/terraform.rc, returning
+// its absolute path
+func WriteCLIConfigE(testRootDir string, mirrorDir string, hostname string) (string, error) {
+	absMirrorDir, err := filepath.Abs(mirrorDir)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.Abs(filepath.Join(testRootDir, cliConfigFileName))
+	if err != nil {
+		return "", err
+	}
+
+	providers := []string{}
+	for _, provider := range MirroredProviders {
+		providers = append(providers, fmt.Sprintf("%q", hostname+"/"+provider))
+	}
+	config := fmt.Sprintf(`provider_installation {
+  filesystem_mirror {
+    path    = %q
+    include = [%s]
+  }
+  direct {
+    exclude = [%s]
+  }
+}
+`, filepath.ToSlash(absMirrorDir), strings.Join(providers, ", "), strings.Join(providers, ", "))
+
+	if err := os.MkdirAll(testRootDir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		return "", fmt.Errorf("unable to write the CLI config %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// Checks that the mirror contains (for the current platform) a version of each mirrored provider matching the
+// version constraints in the required_providers of the Terraform configuration in terraformDir
+func CheckProviderMirrorE(terraformDir string, mirrorDir string, hostname string) error {
+	requirements, err := requiredProviders(terraformDir)
+	if err != nil {
+		return err
+	}
+
+	for _, provider := range MirroredProviders {
+		requirement, ok := requirements[provider]
+		if !ok {
+			continue
+		}
+		constraints, err := version.NewConstraint(requirement.constraint)
+		if err != nil {
+			return fmt.Errorf("invalid version constraint %q for %s in %s: %w", requirement.constraint, provider, requirement.file, err)
+		}
+
+		available := mirrorVersions(mirrorDir, hostname, provider)
+		matched := false
+		for _, v := range available {
+			if parsed, err := version.NewVersion(v); err == nil && constraints.Check(parsed) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			found := "no versions"
+			if len(available) > 0 {
+				found = "only " + strings.Join(available, ", ")
+			}
+			return fmt.Errorf("provider mirror %s has no %s_%s version of %s/%s matching %q (required by %s), it contains %s",
+				mirrorDir, runtime.GOOS, runtime.GOARCH, hostname, provider, requirement.constraint, requirement.file, found)
+		}
+	}
+	return nil
+}
+
+type providerRequirement struct {
+	constraint string
+	file       string
+}
+
+// Returns the version constraint of every provider (namespace/type) in the required_providers blocks of the .tf files
+func requiredProviders(terraformDir string) (map[string]providerRequirement, error) {
+	files, err := filepath.Glob(filepath.Join(terraformDir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	requirements := map[string]providerRequirement{}
+	for _, path := range files {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, diags := hclsyntax.ParseConfig(contents, path, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("unable to parse %s: %s", path, diags.Error())
+		}
+
+		for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+			if block.Type != "terraform" {
+				continue
+			}
+			for _, inner := range block.Body.Blocks {
+				if inner.Type != "required_providers" {
+					continue
+				}
+				for name, attr := range inner.Body.Attributes {
+					value, diags := attr.Expr.Value(nil)
+					if diags.HasErrors() {
+						continue
+					}
+					source, constraint := "hashicorp/"+name, ""
+					if s, ok := stringValue(value); ok {
+						constraint = s
+					} else if value.IsKnown() && !value.IsNull() && value.Type().IsObjectType() {
+						if value.Type().HasAttribute("source") {
+							// A provider without a literal source cannot be matched with the mirror
+							s, ok := stringValue(value.GetAttr("source"))
+							if !ok {
+								continue
+							}
+							source = s
+						}
+						if value.Type().HasAttribute("version") {
+							constraint, _ = stringValue(value.GetAttr("version"))
+						}
+					}
+					// Strip the hostname from fully qualified sources
+					if parts := strings.Split(source, "/"); len(parts) == 3 {
+						source = parts[1] + "/" + parts[2]
+					}
+					if constraint != "" {
+						requirements[strings.ToLower(source)] = providerRequirement{constraint: constraint, file: filepath.Base(path)}
+					}
+				}
+			}
+		}
+	}
+	return requirements, nil
+}
+
+// Returns the string of a known, non null string value
+func stringValue(value cty.Value) (string, bool) {
+	if !value.IsKnown() || value.IsNull() || value.Type() != cty.String {
+		return "", false
+	}
+	return value.AsString(), true
+}
+
+// Returns the versions of the provider (namespace/type) available in the mirror for the current platform
+func mirrorVersions(mirrorDir string, hostname string, provider string) []string {
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+	providerDir := filepath.Join(mirrorDir, hostname, filepath.FromSlash(provider))
+
+	entries, err := os.ReadDir(providerDir)
+	if err != nil {
+		return nil
+	}
+	versions := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if _, err := os.Stat(filepath.Join(providerDir, entry.Name(), platform)); err == nil {
+				versions = append(versions, entry.Name())
+			}
+		} else if match := packedProviderRegexp.FindStringSubmatch(entry.Name()); match != nil && match[3] == platform {
+			versions = append(versions, match[2])
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const versionsTf = `
+terraform {
+  required_version = ">= 1.3.2"
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = ">= 3.30"
+    }
+    azurecaf = {
+      source  = "aztfmod/azurecaf"
+      version = ">= 1.2.22"
+    }
+    random = {
+      source = "hashicorp/random"
+    }
+  }
+}
+`
+
+func TestCheckProviderMirror(t *testing.T) {
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+	terraformDir := t.TempDir()
+	writeFile(t, filepath.Join(terraformDir, "versions.tf"), versionsTf)
+
+	mirrorDir := t.TempDir()
+	writeFile(t, filepath.Join(mirrorDir, "registry.terraform.io", "hashicorp", "azurerm", "3.30.0", platform, "terraform-provider-azurerm_v3.30.0_x5"), "")
+	writeZip(t, filepath.Join(mirrorDir, "registry.terraform.io", "aztfmod", "azurecaf", "terraform-provider-azurecaf_1.2.20_"+platform+".zip"), map[string]string{})
+
+	err := CheckProviderMirrorE(terraformDir, mirrorDir, "registry.terraform.io")
+	assert.ErrorContains(t, err, `version of registry.terraform.io/aztfmod/azurecaf matching ">= 1.2.22" (required by versions.tf), it contains only 1.2.20`)
+
+	writeZip(t, filepath.Join(mirrorDir, "registry.terraform.io", "aztfmod", "azurecaf", "terraform-provider-azurecaf_1.2.22_"+platform+".zip"), map[string]string{})
+	assert.NoError(t, CheckProviderMirrorE(terraformDir, mirrorDir, "registry.terraform.io"))
+
+	// OpenTofu installs the providers from its own registry
+	err = CheckProviderMirrorE(terraformDir, mirrorDir, registryHostname("/usr/local/bin/tofu"))
+	assert.ErrorContains(t, err, "registry.opentofu.org/hashicorp/azurerm")
+	assert.ErrorContains(t, err, "it contains no versions")
+}
+
+func TestRequiredProvidersNonStringAttributes(t *testing.T) {
+	terraformDir := t.TempDir()
+	writeFile(t, filepath.Join(terraformDir, "versions.tf"), `
+terraform {
+  required_providers {
+    azurerm = {
+      source  = "hashicorp/azurerm"
+      version = ">= 3.30"
+    }
+    azurecaf = {
+      source  = null
+      version = ">= 1.2.22"
+    }
+    random = {
+      source  = "hashicorp/random"
+      version = null
+    }
+    null = {
+      source  = "hashicorp/null"
+      version = 3
+    }
+  }
+}
+`)
+
+	requirements, err := requiredProviders(terraformDir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]providerRequirement{
+		"hashicorp/azurerm": {constraint: ">= 3.30", file: "versions.tf"},
+	}, requirements)
+}
+
+func TestWriteCLIConfig(t *testing.T) {
+	testRootDir := filepath.Join(t.TempDir(), "TestExample") + "/"
+	mirrorDir := t.TempDir()
+
+	path, err := WriteCLIConfigE(testRootDir, mirrorDir, "registry.terraform.io")
+	require.NoError(t, err)
+	assert.True(t, filepath.IsAbs(path))
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), `path    = "`+filepath.ToSlash(mirrorDir)+`"`)
+	assert.Contains(t, string(contents), `include = ["registry.terraform.io/hashicorp/azurerm", "registry.terraform.io/aztfmod/azurecaf"]`)
+	assert.Contains(t, string(contents), `exclude = ["registry.terraform.io/hashicorp/azurerm", "registry.terraform.io/aztfmod/azurecaf"]`)
+}
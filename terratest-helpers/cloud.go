@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/require"
+)
+
+// Environment variables used to select the Azure cloud. When none are set the Azure public cloud is used.
+const (
+	// Name of the cloud: public, usgovernment or china
+	AzureEnvironmentEnv = "TERRATEST_AZURE_ENVIRONMENT"
+	// Base URL of a custom Azure Resource Manager endpoint (eg. an Azure Stack Hub or a localhost ARM stand-in)
+	AzureResourceManagerEndpointEnv = "TERRATEST_AZURE_RESOURCE_MANAGER_ENDPOINT"
+	// Audience of the tokens requested for the custom endpoint. Defaults to the endpoint.
+	AzureResourceManagerAudienceEnv = "TERRATEST_AZURE_RESOURCE_MANAGER_AUDIENCE"
+	// Microsoft Entra ID authority of the custom endpoint. Defaults to the environment's authority.
+	AzureAuthorityHostEnv = "TERRATEST_AZURE_AUTHORITY_HOST"
+	// Host serving the /metadata/endpoints document read by the azurerm provider. Defaults to the custom endpoint's host.
+	AzureMetadataHostEnv = "TERRATEST_AZURE_METADATA_HOST"
+)
+
+// Name of the override file configuring the azurerm provider block of the copied Terraform configurations
+const azureCloudOverrideFileName = "terratest_azurerm_override.tf"
+
+// Clouds by azurerm provider environment name
+var azureClouds = map[string]cloud.Configuration{
+	"public":       cloud.AzurePublic,
+	"usgovernment": cloud.AzureGovernment,
+	"china":        cloud.AzureChina,
+}
+
+// Transport used by the validate clients (only replaced by the unit tests, to trust their TLS test server)
+var armTransport policy.Transporter
+
+// The Azure cloud targeted by a test run
+type AzureCloud struct {
+	// azurerm provider environment (public, usgovernment or china)
+	Environment string
+	// azurerm provider metadata_host, only set for a custom endpoint
+	MetadataHost string
+	// Endpoints used by the validate clients and their credentials
+	Configuration cloud.Configuration
+}
+
+// Returns true when the Azure public cloud is used with its default endpoints
+func (c AzureCloud) isDefault() bool {
+	return c.Environment == "public" && c.MetadataHost == ""
+}
+
+// Returns the Azure cloud selected by the TERRATEST_AZURE_ENVIRONMENT and TERRATEST_AZURE_RESOURCE_MANAGER_* variables
+func GetAzureCloudE() (AzureCloud, error) {
+	environment := strings.ToLower(os.Getenv(AzureEnvironmentEnv))
+	if environment == "" {
+		environment = "public"
+	}
+	base, ok := azureClouds[environment]
+	if !ok {
+		return AzureCloud{}, fmt.Errorf("unknown %s %q (expected public, usgovernment or china)", AzureEnvironmentEnv, environment)
+	}
+
+	azureCloud := AzureCloud{Environment: environment, Configuration: base}
+	endpoint := os.Getenv(AzureResourceManagerEndpointEnv)
+	if endpoint == "" {
+		return azureCloud, nil
+	}
+
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Scheme != "https" || endpointURL.Host == "" {
+		return AzureCloud{}, fmt.Errorf("invalid %s %q (expected an https:// URL)", AzureResourceManagerEndpointEnv, endpoint)
+	}
+	audience := os.Getenv(AzureResourceManagerAudienceEnv)
+	if audience == "" {
+		audience = endpoint
+	}
+	authorityHost := os.Getenv(AzureAuthorityHostEnv)
+	if authorityHost == "" {
+		authorityHost = base.ActiveDirectoryAuthorityHost
+	}
+	azureCloud.MetadataHost = os.Getenv(AzureMetadataHostEnv)
+	if azureCloud.MetadataHost == "" {
+		azureCloud.MetadataHost = endpointURL.Host
+	}
+	azureCloud.Configuration = cloud.Configuration{
+		ActiveDirectoryAuthorityHost: authorityHost,
+		Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {Endpoint: endpoint, Audience: audience},
+		},
+	}
+	return azureCloud, nil
+}
+
+// Points the azurerm provider at the selected cloud, through the ARM_ENVIRONMENT and ARM_METADATA_HOSTNAME variables
+// and an override file setting environment and metadata_host in the provider "azurerm" block of the configuration (if
+// it has one). The configuration must be a copy (see CopyTerraformFolder) as the override file is written into it.
+func configureAzureCloud(t *testing.T, options *terraform.Options) {
+	azureCloud, err := GetAzureCloudE()
+	require.NoError(t, err)
+	if azureCloud.isDefault() {
+		return
+	}
+
+	if options.EnvVars == nil {
+		options.EnvVars = map[string]string{}
+	}
+	options.EnvVars["ARM_ENVIRONMENT"] = azureCloud.Environment
+	if azureCloud.MetadataHost != "" {
+		options.EnvVars["ARM_METADATA_HOSTNAME"] = azureCloud.MetadataHost
+	}
+	require.NoError(t, WriteAzureCloudOverrideE(options.TerraformDir, azureCloud))
+}
+
+// Writes the override file setting the azurerm provider's environment and metadata_host, if the Terraform
+// configuration in terraformDir has a provider "azurerm" block
+func WriteAzureCloudOverrideE(terraformDir string, azureCloud AzureCloud) error {
+	hasProvider, err := hasAzurermProvider(terraformDir)
+	if err != nil || !hasProvider {
+		return err
+	}
+
+	config := fmt.Sprintf("provider \"azurerm\" {\n  environment = %q\n", azureCloud.Environment)
+	if azureCloud.MetadataHost != "" {
+		config += fmt.Sprintf("  metadata_host = %q\n", azureCloud.MetadataHost)
+	}
+	config += "}\n"
+
+	path := filepath.Join(terraformDir, azureCloudOverrideFileName)
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		return fmt.Errorf("unable to write the azurerm override %s: %w", path, err)
+	}
+	return nil
+}
+
+// Returns true if a .tf file in terraformDir (other than an override file) has a provider "azurerm" block
+func hasAzurermProvider(terraformDir string) (bool, error) {
+	files, err := filepath.Glob(filepath.Join(terraformDir, "*.tf"))
+	if err != nil {
+		return false, err
+	}
+	for _, path := range files {
+		name := filepath.Base(path)
+		if name == "override.tf" || strings.HasSuffix(name, "_override.tf") {
+			continue
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		file, diags := hclsyntax.ParseConfig(contents, path, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			return false, fmt.Errorf("unable to parse %s: %s", path, diags.Error())
+		}
+		for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+			if block.Type == "provider" && len(block.Labels) == 1 && block.Labels[0] == "azurerm" {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
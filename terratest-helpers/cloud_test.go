@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setAzureCloudEnv(t *testing.T, environment string, endpoint string) {
+	t.Setenv(AzureEnvironmentEnv, environment)
+	t.Setenv(AzureResourceManagerEndpointEnv, endpoint)
+	t.Setenv(AzureResourceManagerAudienceEnv, "")
+	t.Setenv(AzureAuthorityHostEnv, "")
+	t.Setenv(AzureMetadataHostEnv, "")
+}
+
+func TestGetAzureCloudE(t *testing.T) {
+	setAzureCloudEnv(t, "", "")
+	azureCloud, err := GetAzureCloudE()
+	require.NoError(t, err)
+	assert.Equal(t, AzureCloud{Environment: "public", Configuration: cloud.AzurePublic}, azureCloud)
+	assert.True(t, azureCloud.isDefault())
+
+	setAzureCloudEnv(t, "USGovernment", "")
+	azureCloud, err = GetAzureCloudE()
+	require.NoError(t, err)
+	assert.Equal(t, AzureCloud{Environment: "usgovernment", Configuration: cloud.AzureGovernment}, azureCloud)
+
+	setAzureCloudEnv(t, "", "https://localhost:8443/")
+	t.Setenv(AzureResourceManagerAudienceEnv, "https://management.core.windows.net/")
+	azureCloud, err = GetAzureCloudE()
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:8443", azureCloud.MetadataHost)
+	assert.Equal(t, cloud.AzurePublic.ActiveDirectoryAuthorityHost, azureCloud.Configuration.ActiveDirectoryAuthorityHost)
+	assert.Equal(t, cloud.ServiceConfiguration{Endpoint: "https://localhost:8443/", Audience: "https://management.core.windows.net/"},
+		azureCloud.Configuration.Services[cloud.ResourceManager])
+
+	setAzureCloudEnv(t, "germany", "")
+	_, err = GetAzureCloudE()
+	assert.ErrorContains(t, err, `unknown TERRATEST_AZURE_ENVIRONMENT "germany"`)
+
+	setAzureCloudEnv(t, "", "http://localhost:8080")
+	_, err = GetAzureCloudE()
+	assert.ErrorContains(t, err, "expected an https:// URL")
+}
+
+func TestWriteAzureCloudOverrideE(t *testing.T) {
+	dir := t.TempDir()
+	azureCloud := AzureCloud{Environment: "public", MetadataHost: "localhost:8443"}
+
+	// No provider block, nothing to override
+	writeFile(t, filepath.Join(dir, "main.tf"), `resource "azurerm_resource_group" "rg" {}`)
+	require.NoError(t, WriteAzureCloudOverrideE(dir, azureCloud))
+	assert.NoFileExists(t, filepath.Join(dir, azureCloudOverrideFileName))
+
+	writeFile(t, filepath.Join(dir, "provider.tf"), "provider \"azurerm\" {\n  features {}\n}\n")
+	require.NoError(t, WriteAzureCloudOverrideE(dir, azureCloud))
+	contents, err := os.ReadFile(filepath.Join(dir, azureCloudOverrideFileName))
+	require.NoError(t, err)
+	assert.Equal(t, "provider \"azurerm\" {\n  environment = \"public\"\n  metadata_host = \"localhost:8443\"\n}\n", string(contents))
+}
+
+func TestGetAzureResourceECustomEndpoint(t *testing.T) {
+	resourceID := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-snet/providers/Microsoft.Network/virtualNetworks/vnet/subnets/snet"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fake-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/providers/Microsoft.Network"):
+			w.Write([]byte(`{"resourceTypes": [{"resourceType": "virtualNetworks/subnets", "apiVersions": ["2023-05-01", "2023-06-01-preview"]}]}`))
+		case r.URL.Path == resourceID && r.URL.Query().Get("api-version") == "2023-05-01":
+			w.Write([]byte(subnetJSON))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	setAzureCloudEnv(t, "", server.URL)
+	SetCredentialProvider(StaticTokenCredentialProvider{})
+	armTransport = server.Client()
+	defer func() {
+		SetCredentialProvider(nil)
+		armTransport = nil
+		apiVersions.Delete("microsoft.network/virtualnetworks/subnets")
+	}()
+
+	resource, err := GetAzureResourceE(resourceID)
+	require.NoError(t, err)
+	assert.Equal(t, "2023-05-01", resource.APIVersion)
+	AssertProperty(t, resource, "name", "snet")
+}
@@ -0,0 +1,314 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	cp "github.com/otiai10/copy"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Name of the file (at the root of a copied folder) listing extra paths to exclude from the copy. It uses the
+// .gitignore syntax: one pattern per line, # comments, ! to re-include a path, a trailing / to only match folders and
+// a leading or inner / to anchor the pattern to the root of the folder.
+const IgnoreFileName = ".terratestignore"
+
+// Patterns that are never copied into the testRootDir: the module's own test folder, Terraform caches, lock and
+// state files and editor files
+var defaultIgnorePatterns = []string{
+	"test/",
+	".git/",
+	".terraform/",
+	".terraform.lock.hcl",
+	"*.tfstate",
+	"*.tfstate.*",
+	".test-data/",
+	".idea/",
+	".vscode/",
+	".DS_Store",
+	"*.swp",
+	"*.swo",
+	"*~",
+	".#*",
+	"#*#",
+}
+
+// Copy the module into dest, failing the test on any error. See CopyTerraformFolderE.
+func CopyTerraformFolder(t *testing.T, src string, dest string) {
+	require.NoError(t, CopyTerraformFolderE(src, dest))
+}
+
+// Copy the module into dest so the copied tree is self-contained:
+//   - paths matching the default ignore patterns or the module's .terratestignore are skipped
+//   - symlinks are replaced by a copy of their target
+//   - modules referenced with a relative source outside of src (eg. source = "../x") are copied to the same
+//     relative location next to dest
+func CopyTerraformFolderE(src string, dest string) error {
+	return newFolderCopier(dest).copy(src, dest)
+}
+
+type folderCopier struct {
+	// Copied files may not be written outside of this folder (the parent of the first dest)
+	root string
+	// Absolute source folders that have already been copied
+	copied map[string]bool
+}
+
+func newFolderCopier(dest string) *folderCopier {
+	root, _ := filepath.Abs(filepath.Dir(filepath.Clean(dest)))
+	return &folderCopier{root: root, copied: map[string]bool{}}
+}
+
+func (c *folderCopier) copy(src string, dest string) error {
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	if c.copied[absSrc] {
+		return nil
+	}
+	c.copied[absSrc] = true
+
+	info, err := os.Stat(absSrc)
+	if err != nil {
+		return fmt.Errorf("unable to copy %s: %w", src, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("unable to copy %s: not a directory", src)
+	}
+
+	ignore, err := loadIgnorePatterns(absSrc)
+	if err != nil {
+		return err
+	}
+
+	opt := cp.Options{
+		OnSymlink: func(string) cp.SymlinkAction {
+			return cp.Deep
+		},
+		Skip: func(info os.FileInfo, path, _ string) (bool, error) {
+			rel, err := filepath.Rel(absSrc, path)
+			if err != nil {
+				return false, err
+			}
+			isDir := info.IsDir()
+			if info.Mode()&os.ModeSymlink != 0 {
+				if target, err := os.Stat(path); err == nil {
+					isDir = target.IsDir()
+				}
+			}
+			return ignore.matches(filepath.ToSlash(rel), isDir), nil
+		},
+	}
+	if err := cp.Copy(absSrc, dest, opt); err != nil {
+		return fmt.Errorf("unable to copy %s to %s: %w", src, dest, err)
+	}
+
+	return c.copyModuleSources(absSrc, dest, ignore)
+}
+
+// Copies the targets of the relative module sources found in the .tf files of src that point outside of src
+func (c *folderCopier) copyModuleSources(absSrc string, dest string, ignore ignorePatterns) error {
+	return filepath.Walk(absSrc, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(absSrc, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && ignore.matches(filepath.ToSlash(rel), info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || filepath.Ext(path) != ".tf" {
+			return nil
+		}
+
+		sources, err := relativeModuleSources(path)
+		if err != nil {
+			return err
+		}
+		for _, source := range sources {
+			target := filepath.Join(filepath.Dir(path), source)
+			if target == absSrc || strings.HasPrefix(target, absSrc+string(filepath.Separator)) {
+				// Already part of the copied folder
+				continue
+			}
+			targetDest, err := filepath.Abs(filepath.Join(dest, filepath.Dir(rel), source))
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(targetDest, c.root+string(filepath.Separator)) {
+				return fmt.Errorf("module source %q in %s would be copied outside of %s", source, path, c.root)
+			}
+			if err := c.copy(target, targetDest); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns the relative (./ or ../) source of every module block in the .tf file
+func relativeModuleSources(path string) ([]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, diags := hclsyntax.ParseConfig(contents, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("unable to parse %s: %s", path, diags.Error())
+	}
+
+	sources := []string{}
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "module" {
+			continue
+		}
+		attr, ok := block.Body.Attributes["source"]
+		if !ok {
+			continue
+		}
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || !value.Type().Equals(cty.String) || value.IsNull() {
+			continue
+		}
+		source := value.AsString()
+		if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+			sources = append(sources, filepath.FromSlash(source))
+		}
+	}
+	return sources, nil
+}
+
+type ignorePattern struct {
+	regexp  *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+type ignorePatterns []ignorePattern
+
+// Loads the default ignore patterns followed by the patterns from the folder's .terratestignore (if present)
+func loadIgnorePatterns(dir string) (ignorePatterns, error) {
+	lines := append([]string{}, defaultIgnorePatterns...)
+
+	file, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", file.Name(), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	patterns := ignorePatterns{}
+	for _, line := range lines {
+		pattern, ok, err := parseIgnorePattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", IgnoreFileName, line, err)
+		}
+		if ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, nil
+}
+
+func parseIgnorePattern(line string) (ignorePattern, bool, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false, nil
+	}
+
+	pattern := ignorePattern{}
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// Patterns without a slash match at any depth, otherwise they are relative to the root of the folder
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return ignorePattern{}, false, err
+	}
+	pattern.regexp = re
+	return pattern, true, nil
+}
+
+// Converts a .gitignore glob (with * ? [...] and **) into a regular expression
+func globToRegexp(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				expr.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				expr.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String()
+}
+
+// Returns whether the slash separated path (relative to the copied folder) is ignored. The last matching pattern wins.
+func (patterns ignorePatterns) matches(rel string, isDir bool) bool {
+	ignored := false
+	for _, pattern := range patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		if pattern.regexp.MatchString(rel) {
+			ignored = !pattern.negate
+		}
+	}
+	return ignored
+}
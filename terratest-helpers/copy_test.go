@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path string, contents string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+}
+
+func TestCopyTerraformFolderSkipsIgnoredPaths(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "terraform-azurerm-example")
+	writeFile(t, filepath.Join(src, "main.tf"), "")
+	writeFile(t, filepath.Join(src, "terraform.tfstate"), "")
+	writeFile(t, filepath.Join(src, ".terraform.lock.hcl"), "")
+	writeFile(t, filepath.Join(src, ".terraform", "providers", "azurerm"), "")
+	writeFile(t, filepath.Join(src, "test", "example_test.go"), "")
+	writeFile(t, filepath.Join(src, "main.tf.swp"), "")
+	writeFile(t, filepath.Join(src, "docs", "diagram.png"), "")
+	writeFile(t, filepath.Join(src, "docs", "keep.md"), "")
+	writeFile(t, filepath.Join(src, "examples", "main.tf"), "")
+	writeFile(t, filepath.Join(src, IgnoreFileName), "# Not needed to deploy\ndocs/*\n!docs/keep.md\n/examples/\n")
+
+	dest := filepath.Join(t.TempDir(), "TestExample", "module")
+	require.NoError(t, CopyTerraformFolderE(src, dest))
+
+	assert.FileExists(t, filepath.Join(dest, "main.tf"))
+	assert.FileExists(t, filepath.Join(dest, "docs", "keep.md"))
+	for _, path := range []string{"terraform.tfstate", ".terraform.lock.hcl", ".terraform", "test", "main.tf.swp", "docs/diagram.png", "examples"} {
+		assert.NoFileExists(t, filepath.Join(dest, path))
+		assert.NoDirExists(t, filepath.Join(dest, path))
+	}
+}
+
+func TestCopyTerraformFolderResolvesSymlinks(t *testing.T) {
+	shared := filepath.Join(t.TempDir(), "shared.tf")
+	writeFile(t, shared, "locals {}")
+	src := filepath.Join(t.TempDir(), "module")
+	require.NoError(t, os.MkdirAll(src, 0755))
+	require.NoError(t, os.Symlink(shared, filepath.Join(src, "shared.tf")))
+
+	dest := filepath.Join(t.TempDir(), "TestExample", "module")
+	require.NoError(t, CopyTerraformFolderE(src, dest))
+
+	info, err := os.Lstat(filepath.Join(dest, "shared.tf"))
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+}
+
+func TestCopyTerraformFolderCopiesRelativeModuleSources(t *testing.T) {
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "terraform-azurerm-stack", "main.tf"), `
+module "vnet" {
+  source = "../terraform-azurerm-vnet"
+}
+
+module "local" {
+  source = "./modules/local"
+}
+
+module "registry" {
+  source = "hashicorp/example/azurerm"
+}
+`)
+	writeFile(t, filepath.Join(repo, "terraform-azurerm-stack", "modules", "local", "main.tf"), "")
+	writeFile(t, filepath.Join(repo, "terraform-azurerm-vnet", "main.tf"), `
+module "naming" {
+  source = "../terraform-azurerm-naming"
+}
+`)
+	writeFile(t, filepath.Join(repo, "terraform-azurerm-vnet", "test", "vnet_test.go"), "")
+	writeFile(t, filepath.Join(repo, "terraform-azurerm-naming", "main.tf"), "")
+
+	testRootDir := filepath.Join(t.TempDir(), "TestStack")
+	require.NoError(t, CopyTerraformFolderE(filepath.Join(repo, "terraform-azurerm-stack"), filepath.Join(testRootDir, "module")))
+
+	assert.FileExists(t, filepath.Join(testRootDir, "module", "modules", "local", "main.tf"))
+	assert.FileExists(t, filepath.Join(testRootDir, "terraform-azurerm-vnet", "main.tf"))
+	assert.FileExists(t, filepath.Join(testRootDir, "terraform-azurerm-naming", "main.tf"))
+	assert.NoDirExists(t, filepath.Join(testRootDir, "terraform-azurerm-vnet", "test"))
+}
+
+func TestCopyTerraformFolderRejectsSourcesOutsideTestRootDir(t *testing.T) {
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "modules", "stack", "main.tf"), `
+module "vnet" {
+  source = "../../vnet"
+}
+`)
+	writeFile(t, filepath.Join(repo, "vnet", "main.tf"), "")
+
+	err := CopyTerraformFolderE(filepath.Join(repo, "modules", "stack"), filepath.Join(t.TempDir(), "TestStack", "module"))
+	assert.ErrorContains(t, err, "would be copied outside of")
+}
+
+func TestCopyTerraformFolderMissingSource(t *testing.T) {
+	err := CopyTerraformFolderE(filepath.Join(t.TempDir(), "missing"), filepath.Join(t.TempDir(), "module"))
+	assert.ErrorContains(t, err, "unable to copy")
+}
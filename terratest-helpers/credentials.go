@@ -0,0 +1,242 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+// Selects the credential provider used when none is set with SetCredentialProvider: default, cli, env (service
+// principal), workload (federated token file) or static (fake token for a local ARM stand-in)
+const AzureCredentialEnv = "TERRATEST_AZURE_CREDENTIAL"
+
+// Token returned by the static credential provider (defaults to "fake-token")
+const AzureStaticTokenEnv = "TERRATEST_AZURE_STATIC_TOKEN"
+
+// Provides the credential used by every client the validate helpers create, and the ARM_* environment variables
+// configuring the azurerm provider to authenticate the same way in the Terraform runs
+type CredentialProvider interface {
+	// Creates the credential. The options (eg. the cloud configuration) are passed to the credential's own client.
+	Credential(options azcore.ClientOptions) (azcore.TokenCredential, error)
+	// Returns the ARM_* environment variables added to every terraform.Options built by NewTerraformOptions
+	EnvVars() map[string]string
+}
+
+var (
+	credentialProviderMutex sync.Mutex
+	credentialProvider      CredentialProvider
+)
+
+// Sets the credential provider used by all tests in this process, overriding TERRATEST_AZURE_CREDENTIAL
+func SetCredentialProvider(provider CredentialProvider) {
+	credentialProviderMutex.Lock()
+	defer credentialProviderMutex.Unlock()
+	credentialProvider = provider
+}
+
+// Returns the credential provider set with SetCredentialProvider, or the one selected by TERRATEST_AZURE_CREDENTIAL
+func GetCredentialProviderE() (CredentialProvider, error) {
+	credentialProviderMutex.Lock()
+	defer credentialProviderMutex.Unlock()
+	if credentialProvider != nil {
+		return credentialProvider, nil
+	}
+
+	switch name := strings.ToLower(os.Getenv(AzureCredentialEnv)); name {
+	case "", "default":
+		return DefaultCredentialProvider{}, nil
+	case "cli":
+		return AzureCLICredentialProvider{TenantID: getAzureEnv("TENANT_ID")}, nil
+	case "env":
+		return NewServicePrincipalCredentialProviderFromEnv(), nil
+	case "workload":
+		return NewWorkloadIdentityCredentialProviderFromEnv(), nil
+	case "static":
+		return StaticTokenCredentialProvider{Token: os.Getenv(AzureStaticTokenEnv)}, nil
+	default:
+		return nil, fmt.Errorf("unknown %s %q (expected default, cli, env, workload or static)", AzureCredentialEnv, name)
+	}
+}
+
+// Returns the AZURE_<name> environment variable, falling back to the azurerm provider's ARM_<name>
+func getAzureEnv(name string) string {
+	if value := os.Getenv("AZURE_" + name); value != "" {
+		return value
+	}
+	return os.Getenv("ARM_" + name)
+}
+
+// Uses the DefaultAzureCredential chain (environment, workload identity, managed identity then Azure CLI) and leaves
+// the azurerm provider's authentication untouched
+type DefaultCredentialProvider struct{}
+
+func (DefaultCredentialProvider) Credential(options azcore.ClientOptions) (azcore.TokenCredential, error) {
+	return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: options})
+}
+
+func (DefaultCredentialProvider) EnvVars() map[string]string {
+	return map[string]string{}
+}
+
+// Uses the account logged in with az login
+type AzureCLICredentialProvider struct {
+	// Optional tenant to get the tokens from (defaults to the CLI's tenant)
+	TenantID string
+}
+
+func (p AzureCLICredentialProvider) Credential(options azcore.ClientOptions) (azcore.TokenCredential, error) {
+	return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: p.TenantID})
+}
+
+func (p AzureCLICredentialProvider) EnvVars() map[string]string {
+	envVars := map[string]string{"ARM_USE_CLI": "true"}
+	if p.TenantID != "" {
+		envVars["ARM_TENANT_ID"] = p.TenantID
+	}
+	return envVars
+}
+
+// Uses a service principal's client secret
+type ServicePrincipalCredentialProvider struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+}
+
+// Reads the service principal from AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET (or their ARM_ equivalent)
+func NewServicePrincipalCredentialProviderFromEnv() ServicePrincipalCredentialProvider {
+	return ServicePrincipalCredentialProvider{
+		TenantID:     getAzureEnv("TENANT_ID"),
+		ClientID:     getAzureEnv("CLIENT_ID"),
+		ClientSecret: getAzureEnv("CLIENT_SECRET"),
+	}
+}
+
+func (p ServicePrincipalCredentialProvider) Credential(options azcore.ClientOptions) (azcore.TokenCredential, error) {
+	return azidentity.NewClientSecretCredential(p.TenantID, p.ClientID, p.ClientSecret, &azidentity.ClientSecretCredentialOptions{ClientOptions: options})
+}
+
+func (p ServicePrincipalCredentialProvider) EnvVars() map[string]string {
+	return map[string]string{
+		"ARM_USE_CLI":       "false",
+		"ARM_TENANT_ID":     p.TenantID,
+		"ARM_CLIENT_ID":     p.ClientID,
+		"ARM_CLIENT_SECRET": p.ClientSecret,
+	}
+}
+
+// Uses a federated (workload identity) token read from a file, eg. a Kubernetes service account or CI OIDC token
+type WorkloadIdentityCredentialProvider struct {
+	TenantID      string
+	ClientID      string
+	TokenFilePath string
+}
+
+// Reads the workload identity from AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_FEDERATED_TOKEN_FILE (or their ARM_
+// equivalent, ARM_OIDC_TOKEN_FILE_PATH for the token file)
+func NewWorkloadIdentityCredentialProviderFromEnv() WorkloadIdentityCredentialProvider {
+	tokenFilePath := os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+	if tokenFilePath == "" {
+		tokenFilePath = os.Getenv("ARM_OIDC_TOKEN_FILE_PATH")
+	}
+	return WorkloadIdentityCredentialProvider{
+		TenantID:      getAzureEnv("TENANT_ID"),
+		ClientID:      getAzureEnv("CLIENT_ID"),
+		TokenFilePath: tokenFilePath,
+	}
+}
+
+func (p WorkloadIdentityCredentialProvider) Credential(options azcore.ClientOptions) (azcore.TokenCredential, error) {
+	return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+		ClientOptions: options,
+		TenantID:      p.TenantID,
+		ClientID:      p.ClientID,
+		TokenFilePath: p.TokenFilePath,
+	})
+}
+
+func (p WorkloadIdentityCredentialProvider) EnvVars() map[string]string {
+	return map[string]string{
+		"ARM_USE_CLI":              "false",
+		"ARM_USE_OIDC":             "true",
+		"ARM_TENANT_ID":            p.TenantID,
+		"ARM_CLIENT_ID":            p.ClientID,
+		"ARM_OIDC_TOKEN_FILE_PATH": p.TokenFilePath,
+	}
+}
+
+// Fake tenant and client used by the static credential provider
+const fakeTenantOrClientID = "00000000-0000-0000-0000-000000000000"
+
+// Returns a fixed token, for a local ARM stand-in that does not validate tokens
+type StaticTokenCredentialProvider struct {
+	// Defaults to "fake-token"
+	Token string
+}
+
+func (p StaticTokenCredentialProvider) token() string {
+	if p.Token == "" {
+		return "fake-token"
+	}
+	return p.Token
+}
+
+func (p StaticTokenCredentialProvider) Credential(options azcore.ClientOptions) (azcore.TokenCredential, error) {
+	return staticTokenCredential{token: p.token()}, nil
+}
+
+func (p StaticTokenCredentialProvider) EnvVars() map[string]string {
+	return map[string]string{
+		"ARM_USE_CLI":                    "false",
+		"ARM_USE_OIDC":                   "true",
+		"ARM_OIDC_TOKEN":                 p.token(),
+		"ARM_TENANT_ID":                  fakeTenantOrClientID,
+		"ARM_CLIENT_ID":                  fakeTenantOrClientID,
+		"ARM_SKIP_PROVIDER_REGISTRATION": "true",
+	}
+}
+
+type staticTokenCredential struct {
+	token string
+}
+
+func (c staticTokenCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: c.token, ExpiresOn: time.Now().Add(24 * time.Hour)}, nil
+}
+
+// Adds the credential provider's ARM_* environment variables to the options, so the azurerm provider authenticates the
+// same way as the validate helpers
+func configureCredentials(t *testing.T, options *terraform.Options) {
+	provider, err := GetCredentialProviderE()
+	require.NoError(t, err)
+
+	if options.EnvVars == nil {
+		options.EnvVars = map[string]string{}
+	}
+	for key, value := range provider.EnvVars() {
+		options.EnvVars[key] = value
+	}
+}
+
+// Creates a credential with the selected credential provider
+func newCredential(options azcore.ClientOptions) (azcore.TokenCredential, error) {
+	provider, err := GetCredentialProviderE()
+	if err != nil {
+		return nil, err
+	}
+	cred, err := provider.Credential(options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the Azure credential: %w", err)
+	}
+	return cred, nil
+}
@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCredentialProviderE(t *testing.T) {
+	t.Setenv("AZURE_TENANT_ID", "")
+	t.Setenv("AZURE_CLIENT_ID", "")
+	t.Setenv("AZURE_CLIENT_SECRET", "")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "")
+	t.Setenv("ARM_TENANT_ID", "tenant")
+	t.Setenv("ARM_CLIENT_ID", "client")
+	t.Setenv("ARM_CLIENT_SECRET", "secret")
+	t.Setenv("ARM_OIDC_TOKEN_FILE_PATH", "/var/run/token")
+
+	tests := map[string]CredentialProvider{
+		"":         DefaultCredentialProvider{},
+		"CLI":      AzureCLICredentialProvider{TenantID: "tenant"},
+		"env":      ServicePrincipalCredentialProvider{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"},
+		"workload": WorkloadIdentityCredentialProvider{TenantID: "tenant", ClientID: "client", TokenFilePath: "/var/run/token"},
+		"static":   StaticTokenCredentialProvider{},
+	}
+	for name, expected := range tests {
+		t.Setenv(AzureCredentialEnv, name)
+		provider, err := GetCredentialProviderE()
+		require.NoError(t, err)
+		assert.Equal(t, expected, provider, name)
+	}
+
+	t.Setenv(AzureCredentialEnv, "msi")
+	_, err := GetCredentialProviderE()
+	assert.ErrorContains(t, err, `unknown TERRATEST_AZURE_CREDENTIAL "msi"`)
+}
+
+func TestSetCredentialProvider(t *testing.T) {
+	t.Setenv(AzureCredentialEnv, "cli")
+	SetCredentialProvider(StaticTokenCredentialProvider{Token: "token"})
+	defer SetCredentialProvider(nil)
+
+	provider, err := GetCredentialProviderE()
+	require.NoError(t, err)
+	assert.Equal(t, StaticTokenCredentialProvider{Token: "token"}, provider)
+}
+
+func TestCredentialProviderEnvVars(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"ARM_USE_CLI":       "false",
+		"ARM_TENANT_ID":     "tenant",
+		"ARM_CLIENT_ID":     "client",
+		"ARM_CLIENT_SECRET": "secret",
+	}, ServicePrincipalCredentialProvider{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"}.EnvVars())
+
+	assert.Equal(t, map[string]string{"ARM_USE_CLI": "true"}, AzureCLICredentialProvider{}.EnvVars())
+
+	envVars := StaticTokenCredentialProvider{}.EnvVars()
+	assert.Equal(t, "true", envVars["ARM_USE_OIDC"])
+	assert.Equal(t, "fake-token", envVars["ARM_OIDC_TOKEN"])
+}
+
+func TestStaticTokenCredential(t *testing.T) {
+	cred, err := StaticTokenCredentialProvider{Token: "token"}.Credential(azcore.ClientOptions{})
+	require.NoError(t, err)
+
+	token, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}})
+	require.NoError(t, err)
+	assert.Equal(t, "token", token.Token)
+}
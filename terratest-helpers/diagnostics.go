@@ -0,0 +1,57 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// API version used to list the diagnostic settings of a resource
+const diagnosticSettingsAPIVersion = "2021-05-01-preview"
+
+// Gets the diagnostic settings of the resource (eg. the vnet_id output), failing the test on error. A resource
+// without diagnostic settings returns an empty list.
+func GetDiagnosticSettings(t *testing.T, resourceID string) []*AzureResource {
+	settings, err := GetDiagnosticSettingsE(resourceID)
+	if err != nil {
+		recordError(t, err.Error())
+	}
+	require.NoError(t, err)
+	for _, setting := range settings {
+		recordFetchedResource(t, setting)
+	}
+	return settings
+}
+
+// Gets the diagnostic settings of the resource. Throttled and failed lookups are retried until the resource exists
+// (see RetryARME and DefaultRetryOptions).
+func GetDiagnosticSettingsE(resourceID string) ([]*AzureResource, error) {
+	return RetryARME(DefaultRetryOptions, fmt.Sprintf("diagnostic settings of %s", resourceID), func() ([]*AzureResource, error) {
+		return getDiagnosticSettingsE(resourceID)
+	})
+}
+
+// Gets the diagnostic settings of the resource, without retries
+func getDiagnosticSettingsE(resourceID string) ([]*AzureResource, error) {
+	client, err := newARMClient()
+	if err != nil {
+		return nil, err
+	}
+
+	list := struct {
+		Value []map[string]interface{} `json:"value"`
+	}{}
+	path := resourceID + "/providers/Microsoft.Insights/diagnosticSettings"
+	if err := armGet(context.Background(), client, path, diagnosticSettingsAPIVersion, &list); err != nil {
+		return nil, err
+	}
+
+	settings := []*AzureResource{}
+	for _, body := range list.Value {
+		id, _ := body["id"].(string)
+		settings = append(settings, &AzureResource{ID: id, APIVersion: diagnosticSettingsAPIVersion, Body: body})
+	}
+	return settings, nil
+}
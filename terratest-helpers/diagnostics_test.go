@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ID of the virtual network of vnetJSON
+const vnetID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-vnet/providers/Microsoft.Network/virtualNetworks/vnet"
+
+const diagnosticSettingsJSON = `{"value": [{
+  "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-vnet/providers/Microsoft.Network/virtualNetworks/vnet/providers/microsoft.insights/diagnosticSettings/diag-vnet",
+  "name": "diag-vnet",
+  "properties": {
+    "workspaceId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/RG-SECURITY/providers/Microsoft.OperationalInsights/workspaces/workspace",
+    "logs": [{"category": "VMProtectionAlerts", "enabled": true}],
+    "metrics": [{"category": "AllMetrics", "enabled": true}]
+  }
+}]}`
+
+// Starts a fake ARM serving the diagnostic settings of the virtual networks (a virtual network named empty has none)
+func newFakeDiagnosticsARM(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("api-version") != diagnosticSettingsAPIVersion:
+			w.WriteHeader(http.StatusBadRequest)
+		case strings.HasSuffix(r.URL.Path, "/virtualNetworks/empty/providers/Microsoft.Insights/diagnosticSettings"):
+			w.Write([]byte(`{"value": []}`))
+		case strings.HasSuffix(r.URL.Path, "/virtualNetworks/vnet/providers/Microsoft.Insights/diagnosticSettings"):
+			w.Write([]byte(diagnosticSettingsJSON))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	setAzureCloudEnv(t, "", server.URL)
+	SetCredentialProvider(StaticTokenCredentialProvider{})
+	armTransport = server.Client()
+	options := DefaultRetryOptions
+	DefaultRetryOptions = RetryOptions{Timeout: time.Second, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	t.Cleanup(func() {
+		server.Close()
+		SetCredentialProvider(nil)
+		armTransport = nil
+		DefaultRetryOptions = options
+	})
+}
+
+func TestGetDiagnosticSettingsE(t *testing.T) {
+	newFakeDiagnosticsARM(t)
+
+	settings, err := GetDiagnosticSettingsE(vnetID)
+	require.NoError(t, err)
+	require.Len(t, settings, 1)
+	assert.Equal(t, vnetID+"/providers/microsoft.insights/diagnosticSettings/diag-vnet", settings[0].ID)
+	AssertProperty(t, settings[0], "properties.logs[*].category", []string{"VMProtectionAlerts"})
+
+	settings, err = GetDiagnosticSettingsE(strings.TrimSuffix(vnetID, "vnet") + "empty")
+	require.NoError(t, err)
+	assert.Empty(t, settings)
+
+	_, err = GetDiagnosticSettingsE(strings.TrimSuffix(vnetID, "vnet") + "missing")
+	assert.ErrorContains(t, err, "unable to get diagnostic settings of "+strings.TrimSuffix(vnetID, "vnet")+"missing after 1 attempt(s) (status 404")
+}
@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Settings of the polled assertions (see EventuallyE)
+type EventuallyOptions struct {
+	// Maximum time spent polling
+	Timeout time.Duration
+	// Time between two fetches of the value. An interval that is not positive defaults to defaultEventuallyInterval, so
+	// the value is never fetched in a busy loop.
+	Interval time.Duration
+}
+
+// Time between two fetches when the EventuallyOptions have no positive Interval
+const defaultEventuallyInterval = time.Second
+
+// Settings used by Eventually and EventuallyProperty
+var DefaultEventuallyOptions = EventuallyOptions{
+	Timeout:  5 * time.Minute,
+	Interval: 10 * time.Second,
+}
+
+// Error of a condition that did not hold before the timeout, holding the last value (and error) observed
+type EventuallyError struct {
+	Description string
+	Timeout     time.Duration
+	Attempts    int
+	// Last value returned by the getter
+	LastValue interface{}
+	// Last error returned by the getter (nil if the last fetch succeeded)
+	LastErr error
+}
+
+func (e *EventuallyError) Error() string {
+	message := fmt.Sprintf("%s did not hold after %s (%d attempt(s)), last observed value: %s", e.Description, e.Timeout,
+		e.Attempts, formatObservedValue(e.LastValue))
+	if e.LastErr != nil {
+		message += fmt.Sprintf(", last error: %v", e.LastErr)
+	}
+	return RedactString(message)
+}
+
+// Returns the value as JSON (falling back to its Go representation), so the fields of SDK structs are shown rather
+// than their addresses
+func formatObservedValue(value interface{}) string {
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return "<none>"
+	}
+	if encoded, err := json.Marshal(value); err == nil {
+		return string(encoded)
+	}
+	return fmt.Sprintf("%#v", value)
+}
+
+// Fetches the value with get until condition holds, returning the last value. Errors returned by get are retried
+// until the timeout, as resources and their properties can lag behind the end of terraform apply. An *EventuallyError
+// is returned if the condition does not hold before the timeout.
+func EventuallyE[T any](options EventuallyOptions, description string, get func() (T, error), condition func(T) bool) (T, error) {
+	deadline := time.Now().Add(options.Timeout)
+	if options.Interval <= 0 {
+		options.Interval = defaultEventuallyInterval
+	}
+	for attempt := 1; ; attempt++ {
+		value, err := get()
+		if err == nil && condition(value) {
+			return value, nil
+		}
+		if time.Now().Add(options.Interval).After(deadline) {
+			return value, &EventuallyError{Description: description, Timeout: options.Timeout, Attempts: attempt,
+				LastValue: value, LastErr: err}
+		}
+		time.Sleep(options.Interval)
+	}
+}
+
+// Asserts that condition eventually holds for the value fetched with get (see EventuallyE) with the
+// DefaultEventuallyOptions, reporting the last observed value on failure
+func Eventually[T any](t *testing.T, description string, get func() (T, error), condition func(T) bool, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	_, err := EventuallyE(DefaultEventuallyOptions, description, get, condition)
+	if err != nil {
+		recordError(t, err.Error())
+		return assert.Fail(t, err.Error(), msgAndArgs...)
+	}
+	return true
+}
+
+// Asserts that the value at the path of the resource eventually equals expected (see AssertProperty), fetching the
+// resource until it does. The last resource fetched is saved with the artifacts of the test if it fails.
+func EventuallyProperty(t *testing.T, resourceID string, path string, expected interface{}, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	normalized, err := normalizeJSON(expected)
+	if err != nil {
+		return assert.Fail(t, fmt.Sprintf("Unable to compare %s with %#v: %v", path, expected, err), msgAndArgs...)
+	}
+	return Eventually(t, fmt.Sprintf("%s of %s equal to %s", path, resourceID, formatObservedValue(normalized)),
+		func() (interface{}, error) {
+			resource, err := getAzureResourceE(resourceID)
+			if err != nil {
+				return nil, err
+			}
+			recordFetchedResource(t, resource)
+			return resource.Get(path)
+		},
+		func(actual interface{}) bool {
+			return reflect.DeepEqual(normalized, actual)
+		}, msgAndArgs...)
+}
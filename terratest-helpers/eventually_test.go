@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventuallyE(t *testing.T) {
+	options := EventuallyOptions{Timeout: time.Second, Interval: 10 * time.Millisecond}
+	states := []string{"Updating", "Updating", "Succeeded"}
+	attempts := 0
+	get := func() (map[string]string, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errors.New("not found")
+		}
+		return map[string]string{"provisioningState": states[attempts-2]}, nil
+	}
+
+	value, err := EventuallyE(options, "service endpoints provisioned", get, func(value map[string]string) bool {
+		return value["provisioningState"] == "Succeeded"
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Succeeded", value["provisioningState"])
+	assert.Equal(t, 4, attempts)
+}
+
+func TestEventuallyETimeout(t *testing.T) {
+	options := EventuallyOptions{Timeout: 50 * time.Millisecond, Interval: 10 * time.Millisecond}
+	MarkSensitive("/workspaces/eventually")
+
+	_, err := EventuallyE(options, "diagnostic setting", func() (map[string]string, error) {
+		return map[string]string{"workspaceId": "/workspaces/eventually", "state": "Updating"}, nil
+	}, func(value map[string]string) bool {
+		return value["state"] == "Succeeded"
+	})
+	eventuallyErr := &EventuallyError{}
+	require.True(t, errors.As(err, &eventuallyErr))
+	assert.GreaterOrEqual(t, eventuallyErr.Attempts, 3)
+	assert.Regexp(t, `^diagnostic setting did not hold after 50ms \(\d+ attempt\(s\)\), last observed value: `+
+		`\{"state":"Updating","workspaceId":"<redacted>"\}$`, err.Error())
+
+	_, err = EventuallyE(options, "subnet", func() (*AzureResource, error) {
+		return nil, errors.New("not found")
+	}, func(*AzureResource) bool { return true })
+	assert.ErrorContains(t, err, "last observed value: <none>, last error: not found")
+}
+
+func TestEventuallyENonPositiveInterval(t *testing.T) {
+	attempts := 0
+	_, err := EventuallyE(EventuallyOptions{Timeout: 1500 * time.Millisecond}, "subnet delegated", func() (int, error) {
+		attempts++
+		return attempts, nil
+	}, func(int) bool { return false })
+	// Fetched again after defaultEventuallyInterval rather than in a busy loop
+	assert.Equal(t, 2, attempts)
+	assert.ErrorContains(t, err, "(2 attempt(s))")
+}
+
+func TestEventuallyProperty(t *testing.T) {
+	requests := newFakeARM(t, http.StatusNotFound, http.StatusNotFound)
+	options := DefaultEventuallyOptions
+	DefaultEventuallyOptions = EventuallyOptions{Timeout: 5 * time.Second, Interval: 10 * time.Millisecond}
+	t.Cleanup(func() { DefaultEventuallyOptions = options })
+
+	assert.True(t, EventuallyProperty(t, subnetID, "properties.serviceEndpoints[*].service", []string{"Microsoft.Storage", "Microsoft.Sql"}))
+	assert.Equal(t, int32(3), *requests)
+	// The resource is saved with the artifacts if the test fails
+	resources, ok := fetchedResources.Load(t)
+	require.True(t, ok)
+	_, ok = resources.(*sync.Map).Load(subnetID)
+	assert.True(t, ok)
+}
@@ -0,0 +1,348 @@
+package helpers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Expected state of a deployed resource, compared with the resource in one go (see AssertExpectation)
+type Expectation interface {
+	// Returns every field of the resource that does not match the expectation
+	Diff(resource *AzureResource) []Mismatch
+}
+
+// A field of a resource that does not match its expectation
+type Mismatch struct {
+	// Field of the expectation (eg. AddressPrefixes)
+	Field string
+	// Path of the field in the resource (see AzureResource.Get)
+	Path     string
+	Expected interface{}
+	// Value of the resource (nil if the path was not found)
+	Actual interface{}
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s (%s): expected %s, actual %s", m.Field, m.Path, formatObservedValue(m.Expected), formatObservedValue(m.Actual))
+}
+
+// Expected subnet. Zero valued fields are not compared, and lists are compared regardless of their order.
+type SubnetExpectation struct {
+	Name            string
+	AddressPrefixes []string
+	// Enabled or Disabled
+	PrivateEndpointNetworkPolicies    string
+	PrivateLinkServiceNetworkPolicies string
+	// Services of the service endpoints (eg. Microsoft.Storage)
+	ServiceEndpoints []string
+	// Services the subnet is delegated to (eg. Microsoft.Web/serverFarms)
+	Delegations []string
+	// Resource IDs of the associated network security group and route table (compared regardless of case)
+	NetworkSecurityGroupID string
+	RouteTableID           string
+	// Resource IDs of the private endpoints placed in the subnet (compared regardless of case, an empty list expects no
+	// private endpoints)
+	PrivateEndpointIDs []string
+}
+
+// Expected virtual network. Zero valued fields are not compared, and lists are compared regardless of their order.
+type VirtualNetworkExpectation struct {
+	Name string
+	// Compared regardless of case and spaces (eg. CanadaCentral matches canadacentral)
+	Location     string
+	AddressSpace []string
+	DNSServers   []string
+	// Resource ID of the DDoS protection plan (compared regardless of case)
+	DDoSProtectionPlanID string
+	EnableDDoSProtection *bool
+	// BGP community attribute (eg. 12076:20000)
+	BGPCommunity         string
+	FlowTimeoutInMinutes int
+	// Compared as a whole: the resource must have exactly these tags
+	Tags map[string]string
+	// Name of the Edge Zone (compared regardless of case)
+	EdgeZone string
+	// Names of the subnets, whether declared inline or not
+	Subnets []string
+}
+
+// Expected virtual network peering. Zero valued fields are not compared (booleans are set through a pointer, so false
+// is compared).
+type VirtualNetworkPeeringExpectation struct {
+	Name string
+	// Resource ID of the remote virtual network (compared regardless of case)
+	RemoteVirtualNetworkID string
+	// Initiated, Connected or Disconnected
+	PeeringState              string
+	AllowVirtualNetworkAccess *bool
+	AllowForwardedTraffic     *bool
+	AllowGatewayTransit       *bool
+	UseRemoteGateways         *bool
+}
+
+// Expected diagnostic setting (see GetDiagnosticSettings). Zero valued fields are not compared, and lists are
+// compared regardless of their order.
+type DiagnosticSettingExpectation struct {
+	Name string
+	// Resource ID of the Log Analytics workspace (compared regardless of case)
+	WorkspaceID string
+	// Categories of the logs and metrics sent to the workspace (eg. VMProtectionAlerts and AllMetrics). The categories
+	// returned as disabled are not compared.
+	LogCategories    []string
+	MetricCategories []string
+}
+
+// A comparison of a field of an expectation with the value at a path of the resource
+type fieldCheck struct {
+	field    string
+	path     string
+	expected interface{}
+	equal    func(expected interface{}, actual interface{}) bool
+}
+
+func (e SubnetExpectation) Diff(resource *AzureResource) []Mismatch {
+	checks := []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"AddressPrefixes", "properties.addressPrefixes", e.AddressPrefixes, sameElements},
+		{"PrivateEndpointNetworkPolicies", "properties.privateEndpointNetworkPolicies", e.PrivateEndpointNetworkPolicies, equalValues},
+		{"PrivateLinkServiceNetworkPolicies", "properties.privateLinkServiceNetworkPolicies", e.PrivateLinkServiceNetworkPolicies, equalValues},
+		{"ServiceEndpoints", "properties.serviceEndpoints[*].service", e.ServiceEndpoints, sameElements},
+		{"Delegations", "properties.delegations[*].properties.serviceName", e.Delegations, sameElements},
+		{"NetworkSecurityGroupID", "properties.networkSecurityGroup.id", e.NetworkSecurityGroupID, equalFold},
+		{"RouteTableID", "properties.routeTable.id", e.RouteTableID, equalFold},
+		{"PrivateEndpointIDs", "properties.privateEndpoints[*].id", e.PrivateEndpointIDs, sameElementsFold},
+	}
+	// A subnet with a single prefix may only return it as addressPrefix
+	if _, err := resource.Get("properties.addressPrefixes"); err != nil {
+		checks[1].path = "properties.addressPrefix"
+	}
+	return diffFields(resource, checks)
+}
+
+func (e VirtualNetworkExpectation) Diff(resource *AzureResource) []Mismatch {
+	return diffFields(resource, []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"Location", "location", e.Location, equalLocation},
+		{"AddressSpace", "properties.addressSpace.addressPrefixes", e.AddressSpace, sameElements},
+		{"DNSServers", "properties.dhcpOptions.dnsServers", e.DNSServers, sameElements},
+		{"DDoSProtectionPlanID", "properties.ddosProtectionPlan.id", e.DDoSProtectionPlanID, equalFold},
+		{"EnableDDoSProtection", "properties.enableDdosProtection", boolValue(e.EnableDDoSProtection), equalValues},
+		{"BGPCommunity", "properties.bgpCommunities.virtualNetworkCommunity", e.BGPCommunity, equalValues},
+		{"FlowTimeoutInMinutes", "properties.flowTimeoutInMinutes", e.FlowTimeoutInMinutes, equalValues},
+		{"Tags", "tags", e.Tags, equalValues},
+		{"EdgeZone", "extendedLocation.name", e.EdgeZone, equalFold},
+		{"Subnets", "properties.subnets[*].name", e.Subnets, sameElements},
+	})
+}
+
+func (e VirtualNetworkPeeringExpectation) Diff(resource *AzureResource) []Mismatch {
+	return diffFields(resource, []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"RemoteVirtualNetworkID", "properties.remoteVirtualNetwork.id", e.RemoteVirtualNetworkID, equalFold},
+		{"PeeringState", "properties.peeringState", e.PeeringState, equalValues},
+		{"AllowVirtualNetworkAccess", "properties.allowVirtualNetworkAccess", boolValue(e.AllowVirtualNetworkAccess), equalValues},
+		{"AllowForwardedTraffic", "properties.allowForwardedTraffic", boolValue(e.AllowForwardedTraffic), equalValues},
+		{"AllowGatewayTransit", "properties.allowGatewayTransit", boolValue(e.AllowGatewayTransit), equalValues},
+		{"UseRemoteGateways", "properties.useRemoteGateways", boolValue(e.UseRemoteGateways), equalValues},
+	})
+}
+
+func (e DiagnosticSettingExpectation) Diff(resource *AzureResource) []Mismatch {
+	// Only the enabled categories are compared, as Azure also returns disabled ones (eg. AllMetrics with enabled false)
+	enabled := &AzureResource{ID: resource.ID, APIVersion: resource.APIVersion, Body: withEnabledCategories(resource.Body)}
+	return diffFields(enabled, []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"WorkspaceID", "properties.workspaceId", e.WorkspaceID, equalFold},
+		{"LogCategories", "properties.logs[*].category", e.LogCategories, sameElements},
+		{"MetricCategories", "properties.metrics[*].category", e.MetricCategories, sameElements},
+	})
+}
+
+// Returns a copy of the body of a diagnostic setting without its disabled log and metric categories
+func withEnabledCategories(body map[string]interface{}) map[string]interface{} {
+	properties, ok := body["properties"].(map[string]interface{})
+	if !ok {
+		return body
+	}
+	filtered := map[string]interface{}{}
+	for key, value := range properties {
+		filtered[key] = value
+	}
+	for _, key := range []string{"logs", "metrics"} {
+		items, ok := properties[key].([]interface{})
+		if !ok {
+			continue
+		}
+		enabled := []interface{}{}
+		for _, item := range items {
+			if setting, ok := item.(map[string]interface{}); ok && setting["enabled"] == true {
+				enabled = append(enabled, item)
+			}
+		}
+		filtered[key] = enabled
+	}
+
+	copied := map[string]interface{}{}
+	for key, value := range body {
+		copied[key] = value
+	}
+	copied["properties"] = filtered
+	return copied
+}
+
+// Returns the value of an optional boolean of an expectation (nil if not set, so it is not compared)
+func boolValue(value *bool) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// Returns the mismatches of the checks whose expected value is set (booleans are set through a pointer, so false is
+// compared)
+func diffFields(resource *AzureResource, checks []fieldCheck) []Mismatch {
+	mismatches := []Mismatch{}
+	for _, check := range checks {
+		if check.expected == nil {
+			continue
+		}
+		if value := reflect.ValueOf(check.expected); value.Kind() != reflect.Bool && value.IsZero() {
+			continue
+		}
+		expected, err := normalizeJSON(check.expected)
+		if err != nil {
+			expected = check.expected
+		}
+		actual, err := resource.Get(check.path)
+		if err != nil {
+			actual = nil
+		}
+		if !check.equal(expected, actual) {
+			mismatches = append(mismatches, Mismatch{Field: check.field, Path: check.path, Expected: expected, Actual: actual})
+		}
+	}
+	return mismatches
+}
+
+// Compares the list of named items at the path (eg. the security rules of a network security group) with their
+// expectations by name, reporting every missing and extra item. The mismatches of an item are reported as
+// <field>[<name>].<field of the item>.
+func diffNamedItems[E Expectation](resource *AzureResource, field string, path string, expectations []E, name func(E) string) []Mismatch {
+	items := map[string]int{}
+	values, _ := resource.Get(path)
+	deployed, _ := values.([]interface{})
+	for i, value := range deployed {
+		if item, ok := value.(map[string]interface{}); ok {
+			items[fmt.Sprint(item["name"])] = i
+		}
+	}
+
+	mismatches := []Mismatch{}
+	expected := map[string]bool{}
+	for _, expectation := range expectations {
+		expected[name(expectation)] = true
+		i, ok := items[name(expectation)]
+		if !ok {
+			mismatches = append(mismatches, Mismatch{Field: field, Path: path + "[*].name", Expected: name(expectation)})
+			continue
+		}
+		body, _ := deployed[i].(map[string]interface{})
+		for _, mismatch := range expectation.Diff(&AzureResource{Body: body}) {
+			mismatch.Field = fmt.Sprintf("%s[%s].%s", field, name(expectation), mismatch.Field)
+			mismatch.Path = fmt.Sprintf("%s[%d].%s", path, i, mismatch.Path)
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	for _, value := range deployed {
+		if item, ok := value.(map[string]interface{}); ok && !expected[fmt.Sprint(item["name"])] {
+			mismatches = append(mismatches, Mismatch{Field: field, Path: path + "[*].name", Actual: item["name"]})
+		}
+	}
+	return mismatches
+}
+
+func equalValues(expected interface{}, actual interface{}) bool {
+	return reflect.DeepEqual(expected, actual)
+}
+
+func equalFold(expected interface{}, actual interface{}) bool {
+	e, ok1 := expected.(string)
+	a, ok2 := actual.(string)
+	return ok1 && ok2 && strings.EqualFold(e, a)
+}
+
+func equalLocation(expected interface{}, actual interface{}) bool {
+	e, ok1 := expected.(string)
+	a, ok2 := actual.(string)
+	return ok1 && ok2 && strings.EqualFold(strings.ReplaceAll(e, " ", ""), strings.ReplaceAll(a, " ", ""))
+}
+
+// Returns true if both lists hold the same values, regardless of their order. A missing value is an empty list, and
+// a single value a list of one value.
+func sameElements(expected interface{}, actual interface{}) bool {
+	e, ok := expected.([]interface{})
+	if !ok {
+		return false
+	}
+	var a []interface{}
+	switch v := actual.(type) {
+	case nil:
+	case []interface{}:
+		a = v
+	default:
+		a = []interface{}{v}
+	}
+	return len(e) == len(a) && reflect.DeepEqual(sortedValues(e), sortedValues(a))
+}
+
+// Returns true if both lists hold the same strings regardless of their order and case (eg. resource IDs, whose resource
+// group may be returned in upper case). A missing value is an empty list, and a single value a list of one value.
+func sameElementsFold(expected interface{}, actual interface{}) bool {
+	lower := func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			return strings.ToLower(v)
+		case []interface{}:
+			lowered := []interface{}{}
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					item = strings.ToLower(s)
+				}
+				lowered = append(lowered, item)
+			}
+			return lowered
+		}
+		return value
+	}
+	return sameElements(lower(expected), lower(actual))
+}
+
+func sortedValues(values []interface{}) []string {
+	sorted := []string{}
+	for _, value := range values {
+		sorted = append(sorted, formatObservedValue(value))
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Asserts that the resource matches the expectation, reporting every mismatching field at once
+func AssertExpectation(t *testing.T, resource *AzureResource, expectation Expectation, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	mismatches := expectation.Diff(resource)
+	if len(mismatches) == 0 {
+		return true
+	}
+	lines := []string{fmt.Sprintf("%s does not match the expectation (%d mismatch(es)):", resource.ID, len(mismatches))}
+	for _, mismatch := range mismatches {
+		lines = append(lines, "  "+mismatch.String())
+	}
+	message := RedactString(strings.Join(lines, "\n"))
+	recordError(t, message)
+	return assert.Fail(t, message, msgAndArgs...)
+}
@@ -0,0 +1,171 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const vnetJSON = `{
+  "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-vnet/providers/Microsoft.Network/virtualNetworks/vnet",
+  "name": "vnet",
+  "location": "canadacentral",
+  "tags": {"environment": "test", "stack": "stack"},
+  "properties": {
+    "addressSpace": {"addressPrefixes": ["10.5.0.0/24", "10.6.0.0/24"]},
+    "enableDdosProtection": true,
+    "ddosProtectionPlan": {"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/RG-SECURITY/providers/Microsoft.Network/ddosProtectionPlans/ddos"},
+    "bgpCommunities": {"virtualNetworkCommunity": "12076:20000", "regionalCommunity": "12076:50012"},
+    "flowTimeoutInMinutes": 10,
+    "subnets": [{"name": "snet-app"}, {"name": "snet-data"}]
+  }
+}`
+
+func TestSubnetExpectationDiff(t *testing.T) {
+	resource := newTestResource(t, subnetJSON)
+
+	assert.Empty(t, SubnetExpectation{
+		Name:                           "snet",
+		AddressPrefixes:                []string{"10.0.0.0/24"},
+		PrivateEndpointNetworkPolicies: "Disabled",
+		ServiceEndpoints:               []string{"Microsoft.Sql", "Microsoft.Storage"},
+	}.Diff(resource))
+
+	mismatches := SubnetExpectation{
+		Name:                           "snet",
+		AddressPrefixes:                []string{"10.1.0.0/24"},
+		PrivateEndpointNetworkPolicies: "Enabled",
+		ServiceEndpoints:               []string{"Microsoft.Storage"},
+		Delegations:                    []string{},
+		RouteTableID:                   "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-snet/providers/Microsoft.Network/routeTables/rt",
+	}.Diff(resource)
+	fields := []string{}
+	for _, mismatch := range mismatches {
+		fields = append(fields, mismatch.Field)
+	}
+	assert.Equal(t, []string{"AddressPrefixes", "PrivateEndpointNetworkPolicies", "ServiceEndpoints", "RouteTableID"}, fields)
+	assert.Equal(t, `AddressPrefixes (properties.addressPrefixes): expected ["10.1.0.0/24"], actual ["10.0.0.0/24"]`, mismatches[0].String())
+	assert.Equal(t, "RouteTableID (properties.routeTable.id): expected \"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-snet/providers/Microsoft.Network/routeTables/rt\", actual <none>", mismatches[3].String())
+
+	// A single prefix may only be returned as addressPrefix
+	single := newTestResource(t, `{"name": "snet", "properties": {"addressPrefix": "10.0.0.0/24"}}`)
+	assert.Empty(t, SubnetExpectation{AddressPrefixes: []string{"10.0.0.0/24"}}.Diff(single))
+
+	// Private endpoints are compared by ID regardless of case, and a subnet without private endpoints has none
+	assert.Empty(t, SubnetExpectation{PrivateEndpointIDs: []string{}}.Diff(single))
+	withEndpoint := newTestResource(t, `{"name": "snet", "properties": {"privateEndpoints": [
+	  {"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/RG-SNET/providers/Microsoft.Network/privateEndpoints/pe"}
+	]}}`)
+	assert.Empty(t, SubnetExpectation{PrivateEndpointIDs: []string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-snet/providers/Microsoft.Network/privateEndpoints/pe",
+	}}.Diff(withEndpoint))
+	assert.Len(t, SubnetExpectation{PrivateEndpointIDs: []string{}}.Diff(withEndpoint), 1)
+}
+
+func TestVirtualNetworkExpectationDiff(t *testing.T) {
+	resource := newTestResource(t, vnetJSON)
+	enabled, disabled := true, false
+
+	assert.Empty(t, VirtualNetworkExpectation{
+		Name:                 "vnet",
+		Location:             "Canada Central",
+		AddressSpace:         []string{"10.6.0.0/24", "10.5.0.0/24"},
+		DDoSProtectionPlanID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-security/providers/Microsoft.Network/ddosProtectionPlans/ddos",
+		EnableDDoSProtection: &enabled,
+		BGPCommunity:         "12076:20000",
+		FlowTimeoutInMinutes: 10,
+		Tags:                 map[string]string{"stack": "stack", "environment": "test"},
+		Subnets:              []string{"snet-data", "snet-app"},
+	}.Diff(resource))
+
+	mismatches := VirtualNetworkExpectation{
+		Location:             "CanadaEast",
+		EnableDDoSProtection: &disabled,
+		FlowTimeoutInMinutes: 4,
+		Tags:                 map[string]string{"environment": "test"},
+		EdgeZone:             "microsoftlosangeles1",
+	}.Diff(resource)
+	assert.Equal(t, []Mismatch{
+		{Field: "Location", Path: "location", Expected: "CanadaEast", Actual: "canadacentral"},
+		{Field: "EnableDDoSProtection", Path: "properties.enableDdosProtection", Expected: false, Actual: true},
+		{Field: "FlowTimeoutInMinutes", Path: "properties.flowTimeoutInMinutes", Expected: float64(4), Actual: float64(10)},
+		{Field: "Tags", Path: "tags", Expected: map[string]interface{}{"environment": "test"},
+			Actual: map[string]interface{}{"environment": "test", "stack": "stack"}},
+		{Field: "EdgeZone", Path: "extendedLocation.name", Expected: "microsoftlosangeles1", Actual: nil},
+	}, mismatches)
+}
+
+func TestVirtualNetworkPeeringExpectationDiff(t *testing.T) {
+	resource := newTestResource(t, `{
+  "name": "peer-spoke-to-hub",
+  "properties": {
+    "remoteVirtualNetwork": {"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/RG-HUB/providers/Microsoft.Network/virtualNetworks/hub"},
+    "peeringState": "Connected",
+    "allowVirtualNetworkAccess": true,
+    "allowForwardedTraffic": false,
+    "allowGatewayTransit": false,
+    "useRemoteGateways": false
+  }
+}`)
+	enabled, disabled := true, false
+
+	assert.Empty(t, VirtualNetworkPeeringExpectation{
+		Name:                      "peer-spoke-to-hub",
+		RemoteVirtualNetworkID:    "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-hub/providers/Microsoft.Network/virtualNetworks/hub",
+		PeeringState:              "Connected",
+		AllowVirtualNetworkAccess: &enabled,
+		AllowForwardedTraffic:     &disabled,
+		UseRemoteGateways:         &disabled,
+	}.Diff(resource))
+
+	mismatches := VirtualNetworkPeeringExpectation{PeeringState: "Initiated", AllowGatewayTransit: &enabled}.Diff(resource)
+	assert.Equal(t, []Mismatch{
+		{Field: "PeeringState", Path: "properties.peeringState", Expected: "Initiated", Actual: "Connected"},
+		{Field: "AllowGatewayTransit", Path: "properties.allowGatewayTransit", Expected: true, Actual: false},
+	}, mismatches)
+}
+
+func TestDiagnosticSettingExpectationDiff(t *testing.T) {
+	resource := newTestResource(t, `{
+  "name": "diag-vnet",
+  "properties": {
+    "workspaceId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/RG-SECURITY/providers/Microsoft.OperationalInsights/workspaces/workspace",
+    "logs": [{"category": "VMProtectionAlerts", "enabled": true}],
+    "metrics": [{"category": "AllMetrics", "enabled": true}]
+  }
+}`)
+
+	assert.Empty(t, DiagnosticSettingExpectation{
+		Name:             "diag-vnet",
+		WorkspaceID:      "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-security/providers/Microsoft.OperationalInsights/workspaces/workspace",
+		LogCategories:    []string{"VMProtectionAlerts"},
+		MetricCategories: []string{"AllMetrics"},
+	}.Diff(resource))
+
+	mismatches := DiagnosticSettingExpectation{LogCategories: []string{"VMProtectionAlerts", "DDoSProtectionNotifications"}}.Diff(resource)
+	assert.Equal(t, []Mismatch{
+		{Field: "LogCategories", Path: "properties.logs[*].category", Expected: []interface{}{"VMProtectionAlerts", "DDoSProtectionNotifications"},
+			Actual: []interface{}{"VMProtectionAlerts"}},
+	}, mismatches)
+}
+
+func TestDiagnosticSettingExpectationDiffWithDisabledCategories(t *testing.T) {
+	resource := newTestResource(t, `{
+  "name": "diag-vnet",
+  "properties": {
+    "logs": [{"category": "VMProtectionAlerts", "enabled": true}, {"category": "DDoSProtectionNotifications", "enabled": false}],
+    "metrics": [{"category": "AllMetrics", "enabled": false}]
+  }
+}`)
+
+	// The disabled categories are not sent to the workspace
+	assert.Empty(t, DiagnosticSettingExpectation{
+		LogCategories:    []string{"VMProtectionAlerts"},
+		MetricCategories: []string{},
+	}.Diff(resource))
+
+	mismatches := DiagnosticSettingExpectation{MetricCategories: []string{"AllMetrics"}}.Diff(resource)
+	assert.Equal(t, []Mismatch{
+		{Field: "MetricCategories", Path: "properties.metrics[*].category", Expected: []interface{}{"AllMetrics"}, Actual: []interface{}{}},
+	}, mismatches)
+}
@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// An exclusive lock on a file, shared between all the processes (eg. parallel test binaries) using the same path
+type fileLock struct {
+	file *os.File
+}
+
+// Blocks until the exclusive lock on path is acquired, creating the file if needed
+func lockFile(path string) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFileHandle(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to lock %s: %w", path, err)
+	}
+	return &fileLock{file: file}, nil
+}
+
+// Acquires the exclusive lock on path if it is free, returning nil (and no error) if it is held by someone else
+func tryLockFile(path string) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := tryLockFileHandle(file)
+	if err != nil || !locked {
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to lock %s: %w", path, err)
+		}
+		return nil, nil
+	}
+	return &fileLock{file: file}, nil
+}
+
+// Releases the lock
+func (l *fileLock) Unlock() error {
+	defer l.file.Close()
+	return unlockFileHandle(l.file)
+}
@@ -0,0 +1,71 @@
+// Package helpers contains the Terratest helpers shared by the module tests in this repository. Each module's
+// test/go.mod points at this folder with a replace directive so every test runs its stages the same way.
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/thanhpk/randstr"
+)
+
+// Length of the random suffix appended to resource names
+const suffixLength = 8
+
+// Loads the nameSuffix (generating one for setup stage if it does not exist)
+func GetNameSuffix(t *testing.T, testRootDir string) string {
+	nameSuffix := ""
+	if os.Getenv("SKIP_setup_"+testRootDir) == "true" {
+		if ts.IsTestDataPresent(t, testRootDir+".test-data/nameSuffix.json") {
+			nameSuffix = ts.LoadString(t, testRootDir, "nameSuffix")
+		}
+	} else {
+		nameSuffix = strings.ToLower(randstr.String(suffixLength))
+	}
+	return nameSuffix
+}
+
+// Destroys the infrastructure saved in each terraformOptionsDir (in the order given) and removes the content of the
+// testRootDir (except for the stage logs). The artifacts of a failed test are saved first (see SaveArtifacts).
+func TearDown(t *testing.T, testRootDir string, terraformOptionsDirs ...string) {
+	if t.Failed() {
+		SaveArtifacts(t, testRootDir, terraformOptionsDirs...)
+	}
+	for _, terraformOptionsDir := range terraformOptionsDirs {
+		TearDownTerraformOptions(t, testRootDir, terraformOptionsDir)
+	}
+	entries, _ := os.ReadDir(testRootDir)
+	for _, entry := range entries {
+		if entry.Name() != logsDir {
+			os.RemoveAll(filepath.Join(testRootDir, entry.Name()))
+		}
+	}
+}
+
+// Destroys the infrastructure saved in terraformOptionsDir (recovering if it does not exist)
+func TearDownTerraformOptions(t *testing.T, testRootDir string, terraformOptionsDir string) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Recovered from destroy %s: %v\n", terraformOptionsDir, r)
+		}
+	}()
+
+	if _, err := os.Stat(testRootDir + terraformOptionsDir); err == nil {
+		terraformOptions, err := LoadTerraformOptionsE(t, testRootDir, terraformOptionsDir)
+		if err != nil {
+			// Nothing is destroyed with a masked variable
+			recordError(t, err.Error())
+			panic(err)
+		}
+		_, err = terraform.DestroyE(t, terraformOptions)
+		recordTerraformCommand(t, "destroy", terraformOptions.TerraformDir, err)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+const (
+	// Maximum number of deploy slots in use at once by every test (and test binary) sharing the slots directory.
+	// Unset (or 0) to not limit the concurrent deploys.
+	MaxConcurrentDeploysEnv = "TERRATEST_MAX_CONCURRENT_DEPLOYS"
+	// Directory containing the slot lock files. Defaults to <user cache dir>/terratest/deploy-slots.
+	DeploySlotsDirEnv = "TERRATEST_DEPLOY_SLOTS_DIR"
+)
+
+// Number of deploy slots used by each resource of the given type (eg. {"azurerm_virtual_network": 2}). A deploy uses
+// the sum of the slots of its resources (at least 1, at most TERRATEST_MAX_CONCURRENT_DEPLOYS), so with no weights
+// each deploy uses a single slot.
+var ResourceWeights = map[string]int{}
+
+// Lock file held while acquiring slots, so concurrent deploys never hold part of the slots they need
+const deploySlotsGuardFile = "acquire.lock"
+
+// Time between two attempts to acquire the slots
+var deploySlotsPollInterval = 2 * time.Second
+
+// Deploy slots held by a test
+type DeploySlots struct {
+	locks []*fileLock
+}
+
+// Releases the slots
+func (s *DeploySlots) Release() {
+	if s == nil {
+		return
+	}
+	for _, lock := range s.locks {
+		lock.Unlock()
+	}
+	s.locks = nil
+}
+
+// Returns the number of deploy slots and the directory of their lock files (0 slots when deploys are not limited)
+func getDeploySlotsE() (int, string, error) {
+	value := os.Getenv(MaxConcurrentDeploysEnv)
+	if value == "" {
+		return 0, "", nil
+	}
+	capacity, err := strconv.Atoi(value)
+	if err != nil || capacity < 0 {
+		return 0, "", fmt.Errorf("invalid %s %q", MaxConcurrentDeploysEnv, value)
+	}
+
+	dir := os.Getenv(DeploySlotsDirEnv)
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return 0, "", fmt.Errorf("%s is not set and the user cache directory is unknown: %w", DeploySlotsDirEnv, err)
+		}
+		dir = filepath.Join(userCacheDir, "terratest", "deploy-slots")
+	}
+	return capacity, dir, nil
+}
+
+// Blocks until weight deploy slots are acquired, returning the time spent waiting. The slots are lock files, so they
+// are shared by every test binary using the same slots directory and released if a test binary dies.
+func AcquireDeploySlotsE(weight int) (*DeploySlots, time.Duration, error) {
+	capacity, dir, err := getDeploySlotsE()
+	if err != nil || capacity == 0 {
+		return &DeploySlots{}, 0, err
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	if weight > capacity {
+		weight = capacity
+	}
+
+	start := time.Now()
+	for {
+		slots, err := tryAcquireDeploySlots(dir, capacity, weight)
+		if err != nil || slots != nil {
+			return slots, time.Since(start), err
+		}
+		time.Sleep(deploySlotsPollInterval)
+	}
+}
+
+// Acquires weight slots if enough of them are free, returning nil otherwise
+func tryAcquireDeploySlots(dir string, capacity int, weight int) (*DeploySlots, error) {
+	guard, err := lockFile(filepath.Join(dir, deploySlotsGuardFile))
+	if err != nil {
+		return nil, err
+	}
+	defer guard.Unlock()
+
+	slots := &DeploySlots{}
+	for i := 0; i < capacity && len(slots.locks) < weight; i++ {
+		lock, err := tryLockFile(filepath.Join(dir, fmt.Sprintf("slot-%d.lock", i)))
+		if err != nil {
+			slots.Release()
+			return nil, err
+		}
+		if lock != nil {
+			slots.locks = append(slots.locks, lock)
+		}
+	}
+	if len(slots.locks) < weight {
+		slots.Release()
+		return nil, nil
+	}
+	return slots, nil
+}
+
+// Acquires the deploy slots of the Terraform configuration, recording the time spent waiting in the current stage
+// (see RunTestStage)
+func acquireDeploySlots(t *testing.T, terraformDir string) (*DeploySlots, error) {
+	weight, err := deployWeight(terraformDir)
+	if err != nil {
+		return nil, err
+	}
+	slots, wait, err := AcquireDeploySlotsE(weight)
+	if err != nil {
+		return nil, err
+	}
+	if wait >= deploySlotsPollInterval {
+		t.Logf("Waited %.1fs for %d deploy slot(s) to deploy %s", wait.Seconds(), len(slots.locks), terraformDir)
+	}
+	if stage := currentStage(t); stage != nil {
+		stage.QueueWait += wait.Seconds()
+	}
+	return slots, nil
+}
+
+// Returns the number of slots used to deploy the Terraform configuration (the sum of the ResourceWeights of its
+// resources, at least 1)
+func deployWeight(terraformDir string) (int, error) {
+	if len(ResourceWeights) == 0 {
+		return 1, nil
+	}
+	files, err := filepath.Glob(filepath.Join(terraformDir, "*.tf"))
+	if err != nil {
+		return 0, err
+	}
+	weight := 0
+	for _, path := range files {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		file, diags := hclsyntax.ParseConfig(contents, path, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			return 0, fmt.Errorf("unable to parse %s: %s", path, diags.Error())
+		}
+		for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+			if block.Type == "resource" && len(block.Labels) == 2 {
+				weight += ResourceWeights[block.Labels[0]]
+			}
+		}
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	return weight, nil
+}
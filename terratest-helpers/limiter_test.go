@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireDeploySlotsE(t *testing.T) {
+	t.Setenv(MaxConcurrentDeploysEnv, "3")
+	t.Setenv(DeploySlotsDirEnv, t.TempDir())
+	interval := deploySlotsPollInterval
+	deploySlotsPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { deploySlotsPollInterval = interval })
+
+	first, _, err := AcquireDeploySlotsE(2)
+	require.NoError(t, err)
+	assert.Len(t, first.locks, 2)
+
+	// Only one slot is left, so the second deploy waits until the first one releases its slots
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		first.Release()
+	}()
+	second, wait, err := AcquireDeploySlotsE(5)
+	require.NoError(t, err)
+	defer second.Release()
+	assert.Len(t, second.locks, 3)
+	assert.GreaterOrEqual(t, wait, 100*time.Millisecond)
+}
+
+func TestAcquireDeploySlotsEUnlimited(t *testing.T) {
+	t.Setenv(MaxConcurrentDeploysEnv, "")
+	slots, wait, err := AcquireDeploySlotsE(10)
+	require.NoError(t, err)
+	assert.Empty(t, slots.locks)
+	assert.Zero(t, wait)
+
+	t.Setenv(MaxConcurrentDeploysEnv, "many")
+	_, _, err = AcquireDeploySlotsE(1)
+	assert.EqualError(t, err, `invalid TERRATEST_MAX_CONCURRENT_DEPLOYS "many"`)
+}
+
+func TestDeployWeight(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.tf"), `
+resource "azurerm_resource_group" "rg" {}
+resource "azurerm_virtual_network" "vnet" {}
+resource "azurerm_subnet" "subnet" {
+  count = 3
+}
+`)
+
+	weight, err := deployWeight(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, weight)
+
+	weights := ResourceWeights
+	ResourceWeights = map[string]int{"azurerm_virtual_network": 2, "azurerm_subnet": 1}
+	t.Cleanup(func() { ResourceWeights = weights })
+	weight, err = deployWeight(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, weight)
+}
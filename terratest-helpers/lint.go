@@ -0,0 +1,244 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Lowest severity of the findings failing the lint stage (info, warning, error or none). Defaults to error.
+const LintFailSeverityEnv = "TERRATEST_LINT_FAIL_SEVERITY"
+
+// Severity of a lint finding
+type LintSeverity int
+
+const (
+	LintInfo LintSeverity = iota
+	LintWarning
+	LintError
+	// Only used as a fail severity: no finding fails the lint stage
+	LintNone
+)
+
+var lintSeverityNames = map[LintSeverity]string{LintInfo: "info", LintWarning: "warning", LintError: "error", LintNone: "none"}
+
+func (s LintSeverity) String() string {
+	return lintSeverityNames[s]
+}
+
+// Returns the severity named name (eg. warning)
+func ParseLintSeverity(name string) (LintSeverity, error) {
+	for severity, severityName := range lintSeverityNames {
+		if strings.EqualFold(name, severityName) {
+			return severity, nil
+		}
+	}
+	return LintNone, fmt.Errorf("unknown lint severity %q", name)
+}
+
+// A problem found by a lint rule
+type LintFinding struct {
+	// Name of the rule (filled in by LintModuleE)
+	Rule     string
+	Severity LintSeverity
+	// Location of the problem (file and line)
+	Range   hcl.Range
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s [%s]", f.Range.Filename, f.Range.Start.Line, f.Severity, f.Message, f.Rule)
+}
+
+// A parsed .tf file of a module
+type LintFile struct {
+	Path string
+	Body *hclsyntax.Body
+}
+
+// The parsed .tf files of a module, checked by the lint rules
+type LintedModule struct {
+	Dir   string
+	Files []*LintFile
+}
+
+// Returns the blocks of the module's files of the given type (eg. "resource") and, if set, first label (eg. the
+// resource type)
+func (m *LintedModule) Blocks(blockType string, firstLabel string) []*hclsyntax.Block {
+	blocks := []*hclsyntax.Block{}
+	for _, file := range m.Files {
+		for _, block := range file.Body.Blocks {
+			if block.Type == blockType && (firstLabel == "" || (len(block.Labels) > 0 && block.Labels[0] == firstLabel)) {
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	return blocks
+}
+
+// A lint rule: a Go function checking the parsed module
+type LintRule struct {
+	Name string
+	// Default severity of the rule's findings (see LintSeverities to override it)
+	Severity LintSeverity
+	Check    func(module *LintedModule) []LintFinding
+}
+
+// Rules evaluated by the lint stage (see LintModule). Test binaries can add their own rules (eg. in TestMain).
+var LintRules = []LintRule{
+	{Name: "placeholder-resource-name", Severity: LintError, Check: checkPlaceholderResourceNames},
+	{Name: "deprecated-retention-policy", Severity: LintError, Check: checkDeprecatedRetentionPolicies},
+	{Name: "missing-description", Severity: LintWarning, Check: checkMissingDescriptions},
+}
+
+// Severity of the rules' findings by rule name, overriding the rules' default severity (eg.
+// {"missing-description": LintError})
+var LintSeverities = map[string]LintSeverity{}
+
+// Names used as placeholders by coalesce when a variable is null (see checkPlaceholderResourceNames)
+var lintPlaceholderNames = []string{"fake", "dummy", "placeholder"}
+
+// Parses the .tf files of dir
+func ParseLintedModuleE(dir string) (*LintedModule, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	module := &LintedModule{Dir: dir}
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, diags := hclsyntax.ParseConfig(contents, path, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("unable to parse %s: %s", path, diags.Error())
+		}
+		module.Files = append(module.Files, &LintFile{Path: path, Body: file.Body.(*hclsyntax.Body)})
+	}
+	return module, nil
+}
+
+// Evaluates the rules against the .tf files of dir, returning their findings (sorted by file and line)
+func LintModuleE(dir string, rules []LintRule) ([]LintFinding, error) {
+	module, err := ParseLintedModuleE(dir)
+	if err != nil {
+		return nil, err
+	}
+	findings := []LintFinding{}
+	for _, rule := range rules {
+		severity := rule.Severity
+		if override, ok := LintSeverities[rule.Name]; ok {
+			severity = override
+		}
+		for _, finding := range rule.Check(module) {
+			finding.Rule, finding.Severity = rule.Name, severity
+			findings = append(findings, finding)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i].Range, findings[j].Range
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Line < b.Start.Line
+	})
+	return findings, nil
+}
+
+// Evaluates the LintRules against the .tf files of dir (eg. the module copied into the testRootDir), logging every
+// finding. The test fails (and stops) if a finding is at least as severe as TERRATEST_LINT_FAIL_SEVERITY.
+func LintModule(t *testing.T, dir string) []LintFinding {
+	t.Helper()
+	failSeverity := LintError
+	if name := os.Getenv(LintFailSeverityEnv); name != "" {
+		severity, err := ParseLintSeverity(name)
+		if err != nil {
+			t.Fatalf("Invalid %s: %v", LintFailSeverityEnv, err)
+		}
+		failSeverity = severity
+	}
+
+	findings, err := LintModuleE(dir, LintRules)
+	if err != nil {
+		recordError(t, err.Error())
+		t.Fatalf("Unable to lint %s: %v", dir, err)
+	}
+	failed := []string{}
+	for _, finding := range findings {
+		t.Logf("%s", finding)
+		if finding.Severity >= failSeverity {
+			recordError(t, finding.String())
+			failed = append(failed, finding.String())
+		}
+	}
+	if len(failed) > 0 {
+		t.Fatalf("%d lint finding(s) of %s at least as severe as %s:\n%s", len(failed), dir, failSeverity, strings.Join(failed, "\n"))
+	}
+	return findings
+}
+
+// Finds coalesce(var.x, "fake") expressions, which build resource IDs against a placeholder name when the variable
+// is null (the resource using them is usually skipped with count, but the ID is still invalid)
+func checkPlaceholderResourceNames(module *LintedModule) []LintFinding {
+	findings := []LintFinding{}
+	for _, file := range module.Files {
+		hclsyntax.VisitAll(file.Body, func(node hclsyntax.Node) hcl.Diagnostics {
+			call, ok := node.(*hclsyntax.FunctionCallExpr)
+			if !ok || call.Name != "coalesce" {
+				return nil
+			}
+			for _, arg := range call.Args {
+				value, diags := arg.Value(nil)
+				if diags.HasErrors() || !value.Type().Equals(cty.String) || !value.IsKnown() || value.IsNull() {
+					continue
+				}
+				for _, placeholder := range lintPlaceholderNames {
+					if strings.EqualFold(value.AsString(), placeholder) {
+						findings = append(findings, LintFinding{Range: call.Range(), Message: fmt.Sprintf(
+							"coalesce falls back to the placeholder name %q when the variable is null, use a conditional returning null instead", value.AsString())})
+					}
+				}
+			}
+			return nil
+		})
+	}
+	return findings
+}
+
+// Finds the retention_policy blocks of diagnostic settings, which are deprecated by the azurerm provider
+func checkDeprecatedRetentionPolicies(module *LintedModule) []LintFinding {
+	findings := []LintFinding{}
+	for _, resource := range module.Blocks("resource", "azurerm_monitor_diagnostic_setting") {
+		hclsyntax.VisitAll(resource.Body, func(node hclsyntax.Node) hcl.Diagnostics {
+			if block, ok := node.(*hclsyntax.Block); ok && block.Type == "retention_policy" {
+				findings = append(findings, LintFinding{Range: block.TypeRange, Message: fmt.Sprintf(
+					"retention_policy of %s.%s is deprecated by the azurerm provider, remove it", resource.Labels[0], resource.Labels[1])})
+			}
+			return nil
+		})
+	}
+	return findings
+}
+
+// Finds the variables and outputs without a description
+func checkMissingDescriptions(module *LintedModule) []LintFinding {
+	findings := []LintFinding{}
+	for _, blockType := range []string{"variable", "output"} {
+		for _, block := range module.Blocks(blockType, "") {
+			if _, ok := block.Body.Attributes["description"]; !ok && len(block.Labels) > 0 {
+				findings = append(findings, LintFinding{Range: block.DefRange(), Message: fmt.Sprintf(
+					"%s %q has no description", blockType, block.Labels[0])})
+			}
+		}
+	}
+	return findings
+}
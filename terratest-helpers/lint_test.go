@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintModuleE(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "locals.tf"), `locals {
+  nsg_name = var.nsg_name == null ? null : var.nsg_name
+  nsg_id   = format("/networkSecurityGroups/%s", coalesce(var.nsg_name, "fake"))
+}
+`)
+	writeFile(t, filepath.Join(dir, "main.tf"), `resource "azurerm_monitor_diagnostic_setting" "diag" {
+  log {
+    category = "VMProtectionAlerts"
+    retention_policy {
+      enabled = false
+    }
+  }
+}
+`)
+	writeFile(t, filepath.Join(dir, "variables.tf"), `variable "nsg_name" {
+  description = "NSG name"
+  default     = null
+}
+
+variable "location" {}
+
+output "nsg_id" {
+  value = local.nsg_id
+}
+`)
+
+	findings, err := LintModuleE(dir, LintRules)
+	require.NoError(t, err)
+	require.Len(t, findings, 4)
+	assert.Equal(t, filepath.Join(dir, "locals.tf")+`:3: error: coalesce falls back to the placeholder name "fake" when the variable is null, use a conditional returning null instead [placeholder-resource-name]`,
+		findings[0].String())
+	assert.Equal(t, filepath.Join(dir, "main.tf")+":4: error: retention_policy of azurerm_monitor_diagnostic_setting.diag is deprecated by the azurerm provider, remove it [deprecated-retention-policy]",
+		findings[1].String())
+	assert.Equal(t, `variable "location" has no description`, findings[2].Message)
+	assert.Equal(t, 6, findings[2].Range.Start.Line)
+	assert.Equal(t, LintWarning, findings[2].Severity)
+	assert.Equal(t, `output "nsg_id" has no description`, findings[3].Message)
+
+	LintSeverities["missing-description"] = LintError
+	defer delete(LintSeverities, "missing-description")
+	findings, err = LintModuleE(dir, LintRules[2:])
+	require.NoError(t, err)
+	assert.Equal(t, LintError, findings[0].Severity)
+}
+
+func TestParseLintSeverity(t *testing.T) {
+	severity, err := ParseLintSeverity("Warning")
+	require.NoError(t, err)
+	assert.Equal(t, LintWarning, severity)
+
+	_, err = ParseLintSeverity("fatal")
+	assert.EqualError(t, err, `unknown lint severity "fatal"`)
+}
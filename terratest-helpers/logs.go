@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	gotesting "testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// Folder (inside the testRootDir) containing the stage logs. It is kept by TearDown so the logs of the last run can be
+// inspected.
+const logsDir = "logs"
+
+// Log file used for the output logged outside of a stage
+const defaultLogFileName = "terraform"
+
+// Number of lines of the stage log attached to the test error when a stage fails
+const logTailLines = 30
+
+// Serializes the writes to the log files
+var logFilesMutex sync.Mutex
+
+// Logs the Terraform output of a test to <testRootDir>/logs/<stage>.log (eg. logs/deploy.log), using the stage
+// currently run by the test (see RunTestStage)
+type stageLogger struct {
+	testRootDir string
+}
+
+// Creates a logger writing to the stage logs of the test
+func NewStageLogger(testRootDir string) *logger.Logger {
+	return logger.New(stageLogger{testRootDir: testRootDir})
+}
+
+func (l stageLogger) Logf(t testing.TestingT, format string, args ...interface{}) {
+	name := defaultLogFileName
+	if goT, ok := t.(*gotesting.T); ok {
+		if stage := currentStage(goT); stage != nil {
+			name = stage.Stage
+		}
+	}
+	line := fmt.Sprintf("%s %s\n", time.Now().Format(time.RFC3339), RedactString(fmt.Sprintf(format, args...)))
+	if err := appendLog(stageLogPath(l.testRootDir, name), line); err != nil {
+		logger.Default.Logf(t, "Unable to write to the stage log: %v", err)
+		logger.Default.Logf(t, format, args...)
+	}
+}
+
+// Returns the path of the stage's log file
+func stageLogPath(testRootDir string, stage string) string {
+	return filepath.Join(testRootDir, logsDir, stage+".log")
+}
+
+// Appends the line to the log file. The file is opened for each line as the testRootDir is removed (except for the
+// logs folder) and recreated while a stage is running.
+func appendLog(path string, line string) error {
+	logFilesMutex.Lock()
+	defer logFilesMutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Empties the stage's log file, so it only contains the output of the last run
+func resetStageLog(testRootDir string, stage string) {
+	logFilesMutex.Lock()
+	defer logFilesMutex.Unlock()
+	os.Remove(stageLogPath(testRootDir, stage))
+}
+
+// Returns the last lines of the stage's log file
+func tailStageLog(testRootDir string, stage string, lines int) (string, error) {
+	file, err := os.Open(stageLogPath(testRootDir, stage))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	tail := []string{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		tail = append(tail, scanner.Text())
+		if len(tail) > lines {
+			tail = tail[1:]
+		}
+	}
+	return strings.Join(tail, "\n"), scanner.Err()
+}
+
+// Loads the terraform.Options saved in terraformOptionsDir (see SaveTerraformOptions), restoring their masked
+// environment variables and logging their Terraform output to the stage logs. The test fails (and stops) if a masked
+// environment variable cannot be restored.
+func LoadTerraformOptions(t *gotesting.T, testRootDir string, terraformOptionsDir string) *terraform.Options {
+	options, err := LoadTerraformOptionsE(t, testRootDir, terraformOptionsDir)
+	if err != nil {
+		recordError(t, err.Error())
+		t.Fatal(err)
+	}
+	return options
+}
+
+// Loads the terraform.Options saved in terraformOptionsDir (see LoadTerraformOptions), returning an error rather than
+// options holding a masked environment variable
+func LoadTerraformOptionsE(t *gotesting.T, testRootDir string, terraformOptionsDir string) (*terraform.Options, error) {
+	options := ts.LoadTerraformOptions(t, testRootDir+terraformOptionsDir)
+	if err := restoreSensitiveEnvVars(options); err != nil {
+		return nil, fmt.Errorf("unable to restore the options saved in %s: %w", terraformOptionsDir, err)
+	}
+	options.Logger = NewStageLogger(testRootDir)
+	return options, nil
+}
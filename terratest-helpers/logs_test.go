@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStageLogger(t *testing.T) {
+	t.Setenv(ReportDirEnv, t.TempDir())
+	testRootDir := t.TempDir() + "/"
+	stageLogger := NewStageLogger(testRootDir)
+
+	t.Run("TestLogs", func(t *testing.T) {
+		stageLogger.Logf(t, "outside of a stage")
+		RunTestStage(t, "setup_"+testRootDir, func() {
+			stageLogger.Logf(t, "Running command %s", "terraform init")
+			// TearDown keeps the logs written so far
+			writeFile(t, filepath.Join(testRootDir, "terraform", "main.tf"), "")
+			TearDown(t, testRootDir)
+			stageLogger.Logf(t, "Running command %s", "terraform apply")
+		})
+		RunTestStage(t, "deploy_"+testRootDir, func() {
+			stageLogger.Logf(t, "Apply complete!")
+		})
+	})
+
+	assert.NoDirExists(t, filepath.Join(testRootDir, "terraform"))
+	setupLog, err := os.ReadFile(stageLogPath(testRootDir, "setup"))
+	require.NoError(t, err)
+	assert.Contains(t, string(setupLog), "Running command terraform init\n")
+	assert.Contains(t, string(setupLog), "Running command terraform apply\n")
+	assert.NotContains(t, string(setupLog), "Apply complete!")
+
+	tail, err := tailStageLog(testRootDir, "deploy", logTailLines)
+	require.NoError(t, err)
+	assert.Contains(t, tail, "Apply complete!")
+
+	defaultLog, err := os.ReadFile(stageLogPath(testRootDir, defaultLogFileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(defaultLog), "outside of a stage")
+}
+
+func TestTailStageLog(t *testing.T) {
+	testRootDir := t.TempDir() + "/"
+	for i := 1; i <= 50; i++ {
+		require.NoError(t, appendLog(stageLogPath(testRootDir, "deploy"), fmt.Sprintf("line %d\n", i)))
+	}
+
+	tail, err := tailStageLog(testRootDir, "deploy", 3)
+	require.NoError(t, err)
+	assert.Equal(t, "line 48\nline 49\nline 50", tail)
+
+	resetStageLog(testRootDir, "deploy")
+	assert.NoFileExists(t, stageLogPath(testRootDir, "deploy"))
+}
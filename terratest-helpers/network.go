@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-09-01/network"
+	"github.com/stretchr/testify/require"
+)
+
+// API version of the network resources, matching the network package the lookups are decoded into
+const networkAPIVersion = "2019-09-01"
+
+// Gets the virtual network, retrying throttled and failed lookups until it exists (see RetryARM). The subscription
+// defaults to ARM_SUBSCRIPTION_ID when subscriptionID is empty. The virtual network is saved with the artifacts of the
+// test if it fails (see SaveArtifacts).
+func GetVirtualNetwork(t *testing.T, vnetName string, resGroupName string, subscriptionID string) *network.VirtualNetwork {
+	vnet := &network.VirtualNetwork{}
+	getNetworkResource(t, fmt.Sprintf("virtual network %s of %s", vnetName, resGroupName), virtualNetworkPath(vnetName, resGroupName, subscriptionID), vnet)
+	return vnet
+}
+
+// Gets the names and address prefixes of the virtual network's subnets, retrying throttled and failed lookups until the
+// virtual network exists (see RetryARM)
+func GetVirtualNetworkSubnets(t *testing.T, vnetName string, resGroupName string, subscriptionID string) map[string]string {
+	vnet := &network.VirtualNetwork{}
+	getNetworkResource(t, fmt.Sprintf("subnets of virtual network %s of %s", vnetName, resGroupName), virtualNetworkPath(vnetName, resGroupName, subscriptionID), vnet)
+	subnets := map[string]string{}
+	if vnet.Subnets == nil {
+		return subnets
+	}
+	for _, subnet := range *vnet.Subnets {
+		if subnet.Name != nil && subnet.AddressPrefix != nil {
+			subnets[*subnet.Name] = *subnet.AddressPrefix
+		}
+	}
+	return subnets
+}
+
+// Gets the subnet, retrying throttled and failed lookups until it exists (see RetryARM)
+func GetSubnet(t *testing.T, subnetName string, vnetName string, resGroupName string, subscriptionID string) *network.Subnet {
+	subnet := &network.Subnet{}
+	path := virtualNetworkPath(vnetName, resGroupName, subscriptionID) + "/subnets/" + subnetName
+	getNetworkResource(t, fmt.Sprintf("subnet %s of virtual network %s of %s", subnetName, vnetName, resGroupName), path, subnet)
+	return subnet
+}
+
+// Gets the network resource (see RetryARM) and decodes it into v, recording it with the resources fetched by the test
+func getNetworkResource(t *testing.T, description string, path string, v interface{}) {
+	resource := RetryARM(t, description, func() (*AzureResource, error) {
+		return getNetworkResourceE(path)
+	})
+	recordFetchedResource(t, resource)
+	body, err := json.Marshal(resource.Body)
+	if err == nil {
+		err = json.Unmarshal(body, v)
+	}
+	if err != nil {
+		recordError(t, err.Error())
+	}
+	require.NoError(t, err, "Unable to decode the %s", description)
+}
+
+// Gets the network resource through the ARM client (see newARMClient), without retries
+func getNetworkResourceE(path string) (*AzureResource, error) {
+	client, err := newARMClient()
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{}
+	if err := armGet(context.Background(), client, path, networkAPIVersion, &body); err != nil {
+		return nil, err
+	}
+	return &AzureResource{ID: path, APIVersion: networkAPIVersion, Body: body}, nil
+}
+
+// Returns the ARM path of the virtual network
+func virtualNetworkPath(vnetName string, resGroupName string, subscriptionID string) string {
+	if subscriptionID == "" {
+		subscriptionID = os.Getenv("ARM_SUBSCRIPTION_ID")
+	}
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s", subscriptionID, resGroupName, vnetName)
+}
@@ -0,0 +1,88 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const networkVNetJSON = `{
+  "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-vnet/providers/Microsoft.Network/virtualNetworks/vnet",
+  "name": "vnet",
+  "location": "canadacentral",
+  "properties": {
+    "addressSpace": {"addressPrefixes": ["10.0.0.0/16"]},
+    "subnets": [
+      {"name": "snet-app", "properties": {"addressPrefix": "10.0.1.0/24"}},
+      {"name": "snet-data", "properties": {"addressPrefix": "10.0.2.0/24"}}
+    ]
+  }
+}`
+
+const networkSubnetJSON = `{
+  "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-vnet/providers/Microsoft.Network/virtualNetworks/vnet/subnets/snet-app",
+  "name": "snet-app",
+  "properties": {"addressPrefix": "10.0.1.0/24", "privateEndpointNetworkPolicies": "Disabled"}
+}`
+
+// Starts a fake ARM serving a virtual network and one of its subnets, through the selected cloud and credential
+// provider
+func newFakeNetworkARM(t *testing.T) {
+	const vnetPath = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-vnet/providers/Microsoft.Network/virtualNetworks/vnet"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("api-version") != networkAPIVersion || r.Header.Get("Authorization") == "":
+			w.WriteHeader(http.StatusBadRequest)
+		case r.URL.Path == vnetPath:
+			w.Write([]byte(networkVNetJSON))
+		case r.URL.Path == vnetPath+"/subnets/snet-app":
+			w.Write([]byte(networkSubnetJSON))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	setAzureCloudEnv(t, "", server.URL)
+	SetCredentialProvider(StaticTokenCredentialProvider{})
+	armTransport = server.Client()
+	options := DefaultRetryOptions
+	DefaultRetryOptions = RetryOptions{Timeout: time.Second, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	t.Cleanup(func() {
+		server.Close()
+		SetCredentialProvider(nil)
+		armTransport = nil
+		DefaultRetryOptions = options
+	})
+}
+
+func TestGetVirtualNetwork(t *testing.T) {
+	newFakeNetworkARM(t)
+
+	vnet := GetVirtualNetwork(t, "vnet", "rg-vnet", "00000000-0000-0000-0000-000000000000")
+	require.NotNil(t, vnet.AddressSpace)
+	assert.Equal(t, []string{"10.0.0.0/16"}, *vnet.AddressSpace.AddressPrefixes)
+	assert.Equal(t, "canadacentral", *vnet.Location)
+	// The virtual network is saved with the artifacts if the test fails
+	resources, ok := fetchedResources.Load(t)
+	require.True(t, ok)
+	_, ok = resources.(*sync.Map).Load("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-vnet/providers/Microsoft.Network/virtualNetworks/vnet")
+	assert.True(t, ok)
+
+	// The subscription defaults to ARM_SUBSCRIPTION_ID
+	t.Setenv("ARM_SUBSCRIPTION_ID", "00000000-0000-0000-0000-000000000000")
+	assert.Equal(t, map[string]string{"snet-app": "10.0.1.0/24", "snet-data": "10.0.2.0/24"}, GetVirtualNetworkSubnets(t, "vnet", "rg-vnet", ""))
+}
+
+func TestGetSubnet(t *testing.T) {
+	newFakeNetworkARM(t)
+
+	subnet := GetSubnet(t, "snet-app", "vnet", "rg-vnet", "00000000-0000-0000-0000-000000000000")
+	assert.Equal(t, "10.0.1.0/24", *subnet.AddressPrefix)
+	assert.Equal(t, "Disabled", *subnet.PrivateEndpointNetworkPolicies)
+}
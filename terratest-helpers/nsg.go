@@ -0,0 +1,68 @@
+package helpers
+
+// Expected network security group. Zero valued fields are not compared. The security rules are compared by name,
+// reporting every missing and extra rule (the default security rules of Azure are ignored).
+type NetworkSecurityGroupExpectation struct {
+	Name string
+	// Compared regardless of case and spaces (eg. CanadaCentral matches canadacentral)
+	Location string
+	// Every security rule of the network security group (an empty list expects no rules, nil does not compare them)
+	SecurityRules []SecurityRuleExpectation
+}
+
+// Expected security rule of a network security group. Zero valued fields are not compared, and lists are compared
+// regardless of their order.
+type SecurityRuleExpectation struct {
+	Name     string
+	Priority int
+	// Inbound or Outbound (compared regardless of case)
+	Direction string
+	// Allow or Deny (compared regardless of case)
+	Access string
+	// Tcp, Udp, Icmp, Esp, Ah or * (compared regardless of case)
+	Protocol string
+	// Port ranges (eg. 443 or 8000-8080) and address prefixes (eg. 10.0.0.0/24 or a service tag like VirtualNetwork).
+	// A rule with a single range or prefix matches a list of one value.
+	SourcePortRanges           []string
+	DestinationPortRanges      []string
+	SourceAddressPrefixes      []string
+	DestinationAddressPrefixes []string
+}
+
+func (e NetworkSecurityGroupExpectation) Diff(resource *AzureResource) []Mismatch {
+	mismatches := diffFields(resource, []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"Location", "location", e.Location, equalLocation},
+	})
+	if e.SecurityRules == nil {
+		return mismatches
+	}
+	return append(mismatches, diffNamedItems(resource, "SecurityRules", "properties.securityRules", e.SecurityRules,
+		func(rule SecurityRuleExpectation) string { return rule.Name })...)
+}
+
+// Compares a security rule, either fetched on its own or taken from the security rules of its network security group
+func (e SecurityRuleExpectation) Diff(resource *AzureResource) []Mismatch {
+	return diffFields(resource, []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"Priority", "properties.priority", e.Priority, equalValues},
+		{"Direction", "properties.direction", e.Direction, equalFold},
+		{"Access", "properties.access", e.Access, equalFold},
+		{"Protocol", "properties.protocol", e.Protocol, equalFold},
+		{"SourcePortRanges", rangesPath(resource, "sourcePortRanges", "sourcePortRange"), e.SourcePortRanges, sameElements},
+		{"DestinationPortRanges", rangesPath(resource, "destinationPortRanges", "destinationPortRange"), e.DestinationPortRanges, sameElements},
+		{"SourceAddressPrefixes", rangesPath(resource, "sourceAddressPrefixes", "sourceAddressPrefix"), e.SourceAddressPrefixes, sameElements},
+		{"DestinationAddressPrefixes", rangesPath(resource, "destinationAddressPrefixes", "destinationAddressPrefix"), e.DestinationAddressPrefixes, sameElements},
+	})
+}
+
+// Returns the path of the plural property of a security rule (eg. properties.sourcePortRanges) if it holds values, or
+// the path of the singular one (eg. properties.sourcePortRange), as Azure only fills in one of them
+func rangesPath(resource *AzureResource, plural string, singular string) string {
+	if values, err := resource.Get("properties." + plural); err == nil {
+		if list, ok := values.([]interface{}); ok && len(list) > 0 {
+			return "properties." + plural
+		}
+	}
+	return "properties." + singular
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nsgJSON = `{
+  "name": "nsg",
+  "location": "canadacentral",
+  "properties": {
+    "securityRules": [
+      {"name": "allow-https", "properties": {"priority": 100, "direction": "Inbound", "access": "Allow", "protocol": "Tcp",
+        "sourcePortRange": "*", "sourcePortRanges": [], "destinationPortRange": "443", "destinationPortRanges": [],
+        "sourceAddressPrefix": "VirtualNetwork", "sourceAddressPrefixes": [], "destinationAddressPrefixes": ["10.0.0.0/24", "10.0.1.0/24"]}},
+      {"name": "allow-ssh", "properties": {"priority": 200, "direction": "Inbound", "access": "Allow", "protocol": "Tcp",
+        "sourcePortRange": "*", "destinationPortRanges": ["22", "2222"], "sourceAddressPrefix": "10.1.0.0/16", "destinationAddressPrefix": "*"}},
+      {"name": "deny-all", "properties": {"priority": 4096, "direction": "Outbound", "access": "Deny", "protocol": "*",
+        "sourcePortRange": "*", "destinationPortRange": "*", "sourceAddressPrefix": "*", "destinationAddressPrefix": "*"}}
+    ],
+    "defaultSecurityRules": [
+      {"name": "AllowVnetInBound", "properties": {"priority": 65000, "direction": "Inbound", "access": "Allow", "protocol": "*"}}
+    ]
+  }
+}`
+
+func TestNetworkSecurityGroupExpectationDiff(t *testing.T) {
+	resource := newTestResource(t, nsgJSON)
+
+	assert.Empty(t, NetworkSecurityGroupExpectation{
+		Name:     "nsg",
+		Location: "Canada Central",
+		SecurityRules: []SecurityRuleExpectation{
+			{Name: "deny-all", Priority: 4096, Direction: "Outbound", Access: "Deny", Protocol: "*", DestinationPortRanges: []string{"*"}},
+			{Name: "allow-https", Priority: 100, Direction: "inbound", Access: "allow", Protocol: "tcp", SourcePortRanges: []string{"*"},
+				DestinationPortRanges: []string{"443"}, SourceAddressPrefixes: []string{"VirtualNetwork"},
+				DestinationAddressPrefixes: []string{"10.0.1.0/24", "10.0.0.0/24"}},
+			{Name: "allow-ssh", DestinationPortRanges: []string{"2222", "22"}, SourceAddressPrefixes: []string{"10.1.0.0/16"}},
+		},
+	}.Diff(resource))
+
+	// Rules are not compared unless set
+	assert.Empty(t, NetworkSecurityGroupExpectation{Name: "nsg"}.Diff(resource))
+
+	mismatches := NetworkSecurityGroupExpectation{
+		SecurityRules: []SecurityRuleExpectation{
+			{Name: "allow-https", Priority: 110, DestinationPortRanges: []string{"443", "80"}},
+			{Name: "allow-ssh"},
+			{Name: "allow-rdp", Priority: 300},
+		},
+	}.Diff(resource)
+	lines := []string{}
+	for _, mismatch := range mismatches {
+		lines = append(lines, mismatch.String())
+	}
+	assert.Equal(t, []string{
+		"SecurityRules[allow-https].Priority (properties.securityRules[0].properties.priority): expected 110, actual 100",
+		`SecurityRules[allow-https].DestinationPortRanges (properties.securityRules[0].properties.destinationPortRange): expected ["443","80"], actual "443"`,
+		`SecurityRules (properties.securityRules[*].name): expected "allow-rdp", actual <none>`,
+		`SecurityRules (properties.securityRules[*].name): expected <none>, actual "deny-all"`,
+	}, lines)
+}
@@ -0,0 +1,252 @@
+package helpers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	cp "github.com/otiai10/copy"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Provider plugin cache shared by every test (and test binary). Defaults to <user cache dir>/terratest/plugin-cache.
+	PluginCacheDirEnv = "TF_PLUGIN_CACHE_DIR"
+	// Local provider filesystem mirror (packed or unpacked layout). When set, the MirroredProviders are installed from
+	// the mirror (see configureProviderMirror) and the plugin cache is seeded from it.
+	ProviderMirrorDirEnv = "TERRATEST_PROVIDER_MIRROR_DIR"
+)
+
+// Lock file (inside the plugin cache) held while running terraform init, as Terraform does not support concurrent
+// writes to the plugin cache
+const pluginCacheLockFile = ".terratest.lock"
+
+// Packed provider archives in a filesystem mirror (terraform-provider-<type>_<version>_<os>_<arch>.zip)
+var packedProviderRegexp = regexp.MustCompile(`^terraform-provider-(.+)_([^_]+)_([^_]+_[^_]+)\.zip$`)
+
+// Plugin cache directories already seeded by this process
+var seededPluginCaches sync.Map
+
+// Returns the absolute path of the shared plugin cache, creating it if needed
+func GetPluginCacheDirE() (string, error) {
+	dir := os.Getenv(PluginCacheDirEnv)
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("%s is not set and the user cache directory is unknown: %w", PluginCacheDirEnv, err)
+		}
+		dir = filepath.Join(userCacheDir, "terratest", "plugin-cache")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("unable to create the plugin cache %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// Configures the options to use the shared plugin cache (use InitAndApply so concurrent inits do not corrupt it). The
+// .terraform.lock.hcl file is never copied into the testRootDir, so Terraform is allowed to use cached providers
+// without checksums in a lock file.
+func configurePluginCache(t *testing.T, options *terraform.Options) {
+	cacheDir, err := GetPluginCacheDirE()
+	require.NoError(t, err)
+
+	if options.EnvVars == nil {
+		options.EnvVars = map[string]string{}
+	}
+	options.EnvVars[PluginCacheDirEnv] = cacheDir
+	options.EnvVars["TF_PLUGIN_CACHE_MAY_BREAK_DEPENDENCY_LOCK_FILE"] = "true"
+}
+
+// Runs terraform init and apply, failing the test on error. See InitE.
+func InitAndApply(t *testing.T, options *terraform.Options) string {
+	Init(t, options)
+	return Apply(t, options)
+}
+
+// Runs terraform apply, failing the test on error
+func Apply(t *testing.T, options *terraform.Options) string {
+	out, err := ApplyE(t, options)
+	require.NoError(t, err)
+	return out
+}
+
+// Runs terraform apply once the deploy slots of the configuration are acquired (see AcquireDeploySlotsE), recording
+// its exit code in the current stage (see RunTestStage)
+func ApplyE(t *testing.T, options *terraform.Options) (string, error) {
+	slots, err := acquireDeploySlots(t, options.TerraformDir)
+	if err != nil {
+		return "", err
+	}
+	defer slots.Release()
+
+	out, err := terraform.ApplyE(t, options)
+	recordTerraformCommand(t, "apply", options.TerraformDir, err)
+	return out, err
+}
+
+// Runs terraform init, failing the test on error. See InitE.
+func Init(t *testing.T, options *terraform.Options) string {
+	out, err := InitE(t, options)
+	require.NoError(t, err)
+	return out
+}
+
+// Runs terraform init while holding the plugin cache lock, seeding the cache from the provider mirror first (if one
+// is configured). The exit code is recorded in the current stage (see RunTestStage).
+func InitE(t *testing.T, options *terraform.Options) (string, error) {
+	out, err := initE(t, options)
+	recordTerraformCommand(t, "init", options.TerraformDir, err)
+	return out, err
+}
+
+func initE(t *testing.T, options *terraform.Options) (string, error) {
+	cacheDir := options.EnvVars[PluginCacheDirEnv]
+	if cacheDir == "" {
+		return terraform.InitE(t, options)
+	}
+
+	lock, err := lockFile(filepath.Join(cacheDir, pluginCacheLockFile))
+	if err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	if mirrorDir := os.Getenv(ProviderMirrorDirEnv); mirrorDir != "" {
+		if _, seeded := seededPluginCaches.Load(cacheDir); !seeded {
+			if err := SeedPluginCacheE(cacheDir, mirrorDir); err != nil {
+				return "", err
+			}
+			seededPluginCaches.Store(cacheDir, true)
+		}
+	}
+
+	return terraform.InitE(t, options)
+}
+
+// Copies the providers built for the current platform from a filesystem mirror into the plugin cache. Both mirror
+// layouts are supported:
+//   - unpacked: HOSTNAME/NAMESPACE/TYPE/VERSION/OS_ARCH/ (the same layout as the plugin cache)
+//   - packed: HOSTNAME/NAMESPACE/TYPE/terraform-provider-TYPE_VERSION_OS_ARCH.zip
+//
+// Providers already present in the cache are left untouched. The caller must hold the plugin cache lock.
+func SeedPluginCacheE(cacheDir string, mirrorDir string) error {
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+
+	providerDirs, err := filepath.Glob(filepath.Join(mirrorDir, "*", "*", "*"))
+	if err != nil {
+		return err
+	}
+	if len(providerDirs) == 0 {
+		return fmt.Errorf("provider mirror %s does not contain any provider", mirrorDir)
+	}
+
+	for _, providerDir := range providerDirs {
+		rel, err := filepath.Rel(mirrorDir, providerDir)
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(providerDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			var version string
+			if entry.IsDir() {
+				version = entry.Name()
+				src := filepath.Join(providerDir, version, platform)
+				if _, err := os.Stat(src); err != nil {
+					continue
+				}
+				if err := seedProvider(cacheDir, rel, version, platform, func(dest string) error {
+					return cp.Copy(src, dest)
+				}); err != nil {
+					return err
+				}
+			} else if match := packedProviderRegexp.FindStringSubmatch(entry.Name()); match != nil && match[3] == platform {
+				version = match[2]
+				archive := filepath.Join(providerDir, entry.Name())
+				if err := seedProvider(cacheDir, rel, version, platform, func(dest string) error {
+					return unzip(archive, dest)
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Installs a provider version in the cache (through a temporary folder so a failed copy never leaves a partial
+// provider behind)
+func seedProvider(cacheDir string, provider string, version string, platform string, install func(dest string) error) error {
+	dest := filepath.Join(cacheDir, provider, version, platform)
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+	tmp := dest + ".tmp"
+	os.RemoveAll(tmp)
+	if err := install(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("unable to seed %s %s into the plugin cache: %w", filepath.ToSlash(provider), version, err)
+	}
+	return os.Rename(tmp, dest)
+}
+
+// Extracts the zip archive into dest
+func unzip(archive string, dest string) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		path := filepath.Join(dest, file.Name)
+		if !strings.HasPrefix(path, filepath.Clean(dest)+string(filepath.Separator)) {
+			return fmt.Errorf("%s contains an invalid path %q", archive, file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractFile(file, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(file *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, file.Mode()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	writer := zip.NewWriter(file)
+	for name, contents := range files {
+		w, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, file.Close())
+}
+
+func TestSeedPluginCache(t *testing.T) {
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+	mirrorDir := t.TempDir()
+	cacheDir := t.TempDir()
+
+	// Unpacked azurerm provider
+	writeFile(t, filepath.Join(mirrorDir, "registry.terraform.io", "hashicorp", "azurerm", "3.30.0", platform, "terraform-provider-azurerm_v3.30.0_x5"), "azurerm")
+	writeFile(t, filepath.Join(mirrorDir, "registry.terraform.io", "hashicorp", "azurerm", "3.30.0", "plan9_386", "terraform-provider-azurerm_v3.30.0_x5"), "azurerm")
+	// Packed azurecaf provider
+	writeZip(t, filepath.Join(mirrorDir, "registry.terraform.io", "aztfmod", "azurecaf", "terraform-provider-azurecaf_1.2.22_"+platform+".zip"), map[string]string{
+		"terraform-provider-azurecaf_v1.2.22": "azurecaf",
+	})
+
+	require.NoError(t, SeedPluginCacheE(cacheDir, mirrorDir))
+
+	assert.FileExists(t, filepath.Join(cacheDir, "registry.terraform.io", "hashicorp", "azurerm", "3.30.0", platform, "terraform-provider-azurerm_v3.30.0_x5"))
+	assert.FileExists(t, filepath.Join(cacheDir, "registry.terraform.io", "aztfmod", "azurecaf", "1.2.22", platform, "terraform-provider-azurecaf_v1.2.22"))
+	assert.NoDirExists(t, filepath.Join(cacheDir, "registry.terraform.io", "hashicorp", "azurerm", "3.30.0", "plan9_386"))
+
+	// Seeding again leaves the cached providers untouched
+	require.NoError(t, SeedPluginCacheE(cacheDir, mirrorDir))
+}
+
+func TestSeedPluginCacheEmptyMirror(t *testing.T) {
+	err := SeedPluginCacheE(t.TempDir(), t.TempDir())
+	assert.ErrorContains(t, err, "does not contain any provider")
+}
+
+func TestLockFileIsExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), pluginCacheLockFile)
+	lock, err := lockFile(path)
+	require.NoError(t, err)
+
+	// FailNow must not be called outside the test goroutine, so the error of the second lock is asserted below
+	acquired := make(chan time.Time, 1)
+	errs := make(chan error, 1)
+	go func() {
+		second, err := lockFile(path)
+		if err != nil {
+			errs <- err
+			return
+		}
+		acquired <- time.Now()
+		errs <- second.Unlock()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	released := time.Now()
+	require.NoError(t, lock.Unlock())
+	require.NoError(t, <-errs)
+	assert.True(t, (<-acquired).After(released))
+}
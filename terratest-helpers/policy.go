@@ -0,0 +1,152 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// Query evaluated against the plan: every policy is a package under terraform (eg. package terraform.vnet_ddos)
+// defining deny rules that return a message per violation
+const policyQuery = "data.terraform[policy].deny[msg]"
+
+// Name of the plan file written next to the copied configuration
+const policyPlanFileName = "terratest.tfplan"
+
+// Settings of the plan policy evaluation (see CheckPlanPolicies)
+type PolicyOptions struct {
+	// Address prefixes of the resources that are not judged (eg. "module.setup" for fixtures declared in the same
+	// configuration as the module). Data sources are never judged.
+	ExcludeAddresses []string
+	// Addresses excepted from a policy, by policy name (eg. {"subnet_nsg": {"azurerm_subnet.subnet"}}). They are
+	// passed to the policies as input.exceptions.
+	Exceptions map[string][]string
+}
+
+// A deny message returned by a policy
+type PolicyViolation struct {
+	// Package of the policy, without the terraform. prefix (eg. vnet_ddos)
+	Policy  string
+	Message string
+}
+
+func (v PolicyViolation) String() string {
+	return fmt.Sprintf("%s: %s", v.Policy, v.Message)
+}
+
+// Returns the JSON plan (as output by terraform show -json) with the resource changes not judged by the policies
+// removed, and the exceptions added as input.exceptions
+func policyInput(plan []byte, options PolicyOptions) (map[string]interface{}, error) {
+	input := map[string]interface{}{}
+	if err := json.Unmarshal(plan, &input); err != nil {
+		return nil, fmt.Errorf("unable to parse the JSON plan: %w", err)
+	}
+
+	changes, _ := input["resource_changes"].([]interface{})
+	judged := []interface{}{}
+	for _, change := range changes {
+		resource, ok := change.(map[string]interface{})
+		if !ok || resource["mode"] == "data" {
+			continue
+		}
+		address, _ := resource["address"].(string)
+		excluded := false
+		for _, prefix := range options.ExcludeAddresses {
+			if address == prefix || strings.HasPrefix(address, prefix+".") || strings.HasPrefix(address, prefix+"[") {
+				excluded = true
+			}
+		}
+		if !excluded {
+			judged = append(judged, resource)
+		}
+	}
+	input["resource_changes"] = judged
+
+	exceptions := map[string]interface{}{}
+	for policy, addresses := range options.Exceptions {
+		exceptions[policy] = addresses
+	}
+	input["exceptions"] = exceptions
+	return input, nil
+}
+
+// Evaluates the Rego policies (and data files) of policyDir against the JSON plan, returning their violations
+// (sorted by policy and message)
+func EvaluatePlanPoliciesE(plan []byte, policyDir string, options PolicyOptions) ([]PolicyViolation, error) {
+	input, err := policyInput(plan, options)
+	if err != nil {
+		return nil, err
+	}
+	query, err := rego.New(rego.Query(policyQuery), rego.Load([]string{policyDir}, nil)).PrepareForEval(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the policies of %s: %w", policyDir, err)
+	}
+	results, err := query.Eval(context.Background(), rego.EvalInput(input))
+	if err != nil {
+		return nil, fmt.Errorf("unable to evaluate the policies of %s: %w", policyDir, err)
+	}
+
+	violations := []PolicyViolation{}
+	for _, result := range results {
+		violation := PolicyViolation{Policy: fmt.Sprint(result.Bindings["policy"]), Message: fmt.Sprint(result.Bindings["msg"])}
+		if msg, ok := result.Bindings["msg"].(string); ok {
+			violation.Message = msg
+		}
+		violations = append(violations, violation)
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Policy != violations[j].Policy {
+			return violations[i].Policy < violations[j].Policy
+		}
+		return violations[i].Message < violations[j].Message
+	})
+	return violations, nil
+}
+
+// Plans the configuration of the options (running terraform init first) and evaluates the Rego policies of policyDir
+// against the JSON plan (see EvaluatePlanPoliciesE)
+func CheckPlanPoliciesE(t *testing.T, options *terraform.Options, policyDir string, policyOptions PolicyOptions) ([]PolicyViolation, error) {
+	if _, err := InitE(t, options); err != nil {
+		return nil, err
+	}
+	planOptions := *options
+	planOptions.PlanFilePath = filepath.Join(options.TerraformDir, policyPlanFileName)
+	_, err := terraform.PlanE(t, &planOptions)
+	recordTerraformCommand(t, "plan", options.TerraformDir, err)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := terraform.ShowE(t, &planOptions)
+	recordTerraformCommand(t, "show", options.TerraformDir, err)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluatePlanPoliciesE([]byte(plan), policyDir, policyOptions)
+}
+
+// Plans the configuration of the options and evaluates the Rego policies of policyDir against the JSON plan, failing
+// (and stopping) the test with every violation, so nothing is applied
+func CheckPlanPolicies(t *testing.T, options *terraform.Options, policyDir string, policyOptions PolicyOptions) {
+	t.Helper()
+	violations, err := CheckPlanPoliciesE(t, options, policyDir, policyOptions)
+	if err != nil {
+		recordError(t, err.Error())
+		t.Fatalf("Unable to check the plan of %s against the policies of %s: %v", options.TerraformDir, policyDir, err)
+	}
+	if len(violations) == 0 {
+		return
+	}
+	lines := []string{}
+	for _, violation := range violations {
+		recordError(t, violation.String())
+		lines = append(lines, violation.String())
+	}
+	t.Fatalf("The plan of %s violates %d policy rule(s) of %s:\n%s", options.TerraformDir, len(violations), policyDir, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Folder of the security policies shared by the module tests
+const policyDir = "../policies"
+
+const policyPlanJSON = `{
+  "format_version": "1.0",
+  "resource_changes": [
+    {"address": "data.azurerm_subscription.current", "mode": "data", "type": "azurerm_subscription", "change": {"actions": ["read"], "after": {}}},
+    {"address": "azurerm_virtual_network.vnet", "mode": "managed", "type": "azurerm_virtual_network",
+     "change": {"actions": ["create"], "after": {"ddos_protection_plan": [{"enable": true}]}}},
+    {"address": "azurerm_virtual_network.unprotected", "mode": "managed", "type": "azurerm_virtual_network",
+     "change": {"actions": ["create"], "after": {"ddos_protection_plan": []}}},
+    {"address": "module.setup.azurerm_virtual_network.fixture", "module_address": "module.setup", "mode": "managed", "type": "azurerm_virtual_network",
+     "change": {"actions": ["create"], "after": {}}},
+    {"address": "azurerm_subnet.subnet", "mode": "managed", "type": "azurerm_subnet", "change": {"actions": ["create"], "after": {}}},
+    {"address": "azurerm_subnet_network_security_group_association.subnet_association[0]", "mode": "managed",
+     "type": "azurerm_subnet_network_security_group_association", "index": 0, "change": {"actions": ["create"], "after": {}}},
+    {"address": "azurerm_subnet.other[\"app\"]", "mode": "managed", "type": "azurerm_subnet", "index": "app", "change": {"actions": ["create"], "after": {}}},
+    {"address": "azurerm_subnet.other[\"db\"]", "mode": "managed", "type": "azurerm_subnet", "index": "db", "change": {"actions": ["create"], "after": {}}}
+  ]
+}`
+
+func TestEvaluatePlanPoliciesE(t *testing.T) {
+	violations, err := EvaluatePlanPoliciesE([]byte(policyPlanJSON), policyDir, PolicyOptions{ExcludeAddresses: []string{"module.setup"}})
+	require.NoError(t, err)
+	assert.Equal(t, []PolicyViolation{
+		{Policy: "subnet_nsg", Message: `azurerm_subnet.other["app"] must be associated with a network security group or have an exception`},
+		{Policy: "subnet_nsg", Message: `azurerm_subnet.other["db"] must be associated with a network security group or have an exception`},
+		{Policy: "vnet_ddos", Message: "azurerm_virtual_network.unprotected must have DDoS protection enabled"},
+	}, violations)
+
+	// The fixtures are judged unless excluded, and an exception covers every instance of a resource
+	violations, err = EvaluatePlanPoliciesE([]byte(policyPlanJSON), policyDir, PolicyOptions{
+		Exceptions: map[string][]string{"subnet_nsg": {"azurerm_subnet.other"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"vnet_ddos: azurerm_virtual_network.unprotected must have DDoS protection enabled",
+		"vnet_ddos: module.setup.azurerm_virtual_network.fixture must have DDoS protection enabled",
+	}, []string{violations[0].String(), violations[1].String()})
+	assert.Len(t, violations, 2)
+
+	// A virtual network without DDoS protection (eg. in a dev environment) can be excepted too
+	violations, err = EvaluatePlanPoliciesE([]byte(policyPlanJSON), policyDir, PolicyOptions{
+		ExcludeAddresses: []string{"module.setup"},
+		Exceptions: map[string][]string{
+			"subnet_nsg": {"azurerm_subnet.other"},
+			"vnet_ddos":  {"azurerm_virtual_network.unprotected"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+}
+
+func TestEvaluatePlanPoliciesEInvalid(t *testing.T) {
+	_, err := EvaluatePlanPoliciesE([]byte("not json"), policyDir, PolicyOptions{})
+	assert.ErrorContains(t, err, "unable to parse the JSON plan")
+
+	dir := t.TempDir()
+	writeFile(t, dir+"/invalid.rego", "package terraform.invalid\n\ndeny[msg] {\n")
+	_, err = EvaluatePlanPoliciesE([]byte(policyPlanJSON), dir, PolicyOptions{})
+	assert.ErrorContains(t, err, "unable to load the policies of "+dir)
+}
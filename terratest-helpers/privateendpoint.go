@@ -0,0 +1,49 @@
+package helpers
+
+// Expected private endpoint. Zero valued fields are not compared. The connections are compared by name, reporting every
+// missing and extra connection.
+type PrivateEndpointExpectation struct {
+	Name string
+	// Compared regardless of case and spaces (eg. CanadaCentral matches canadacentral)
+	Location string
+	// Resource ID of the subnet the private endpoint is placed in (compared regardless of case)
+	SubnetID string
+	// Every automatically approved connection of the private endpoint (an empty list expects no connections, nil does
+	// not compare them)
+	Connections []PrivateLinkServiceConnectionExpectation
+}
+
+// Expected connection of a private endpoint to a private link resource. Zero valued fields are not compared, and lists
+// are compared regardless of their order.
+type PrivateLinkServiceConnectionExpectation struct {
+	Name string
+	// Resource ID of the private link resource (eg. a storage account), compared regardless of case
+	PrivateLinkServiceID string
+	// Subresources of the private link resource (eg. blob)
+	GroupIDs []string
+	// Pending, Approved, Rejected or Disconnected
+	Status string
+}
+
+func (e PrivateEndpointExpectation) Diff(resource *AzureResource) []Mismatch {
+	mismatches := diffFields(resource, []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"Location", "location", e.Location, equalLocation},
+		{"SubnetID", "properties.subnet.id", e.SubnetID, equalFold},
+	})
+	if e.Connections == nil {
+		return mismatches
+	}
+	return append(mismatches, diffNamedItems(resource, "Connections", "properties.privateLinkServiceConnections", e.Connections,
+		func(connection PrivateLinkServiceConnectionExpectation) string { return connection.Name })...)
+}
+
+// Compares a connection taken from the connections of its private endpoint
+func (e PrivateLinkServiceConnectionExpectation) Diff(resource *AzureResource) []Mismatch {
+	return diffFields(resource, []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"PrivateLinkServiceID", "properties.privateLinkServiceId", e.PrivateLinkServiceID, equalFold},
+		{"GroupIDs", "properties.groupIds", e.GroupIDs, sameElements},
+		{"Status", "properties.privateLinkServiceConnectionState.status", e.Status, equalValues},
+	})
+}
@@ -0,0 +1,57 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const privateEndpointJSON = `{
+  "name": "pe",
+  "location": "canadacentral",
+  "properties": {
+    "subnet": {"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/RG-PE/providers/Microsoft.Network/virtualNetworks/vnet/subnets/snet"},
+    "privateLinkServiceConnections": [
+      {"name": "psc", "properties": {
+        "privateLinkServiceId": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-pe/providers/Microsoft.Storage/storageAccounts/st",
+        "groupIds": ["blob"],
+        "privateLinkServiceConnectionState": {"status": "Approved", "description": "Auto-Approved"}
+      }}
+    ]
+  }
+}`
+
+func TestPrivateEndpointExpectationDiff(t *testing.T) {
+	resource := newTestResource(t, privateEndpointJSON)
+
+	assert.Empty(t, PrivateEndpointExpectation{
+		Name:     "pe",
+		Location: "Canada Central",
+		SubnetID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-pe/providers/Microsoft.Network/virtualNetworks/vnet/subnets/snet",
+		Connections: []PrivateLinkServiceConnectionExpectation{{
+			Name:                 "psc",
+			PrivateLinkServiceID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-pe/providers/Microsoft.Storage/storageAccounts/st",
+			GroupIDs:             []string{"blob"},
+			Status:               "Approved",
+		}},
+	}.Diff(resource))
+
+	// Connections are not compared unless set
+	assert.Empty(t, PrivateEndpointExpectation{Name: "pe"}.Diff(resource))
+
+	mismatches := PrivateEndpointExpectation{
+		Connections: []PrivateLinkServiceConnectionExpectation{
+			{Name: "psc", GroupIDs: []string{"file"}, Status: "Pending"},
+			{Name: "psc-queue"},
+		},
+	}.Diff(resource)
+	lines := []string{}
+	for _, mismatch := range mismatches {
+		lines = append(lines, mismatch.String())
+	}
+	assert.Equal(t, []string{
+		`Connections[psc].GroupIDs (properties.privateLinkServiceConnections[0].properties.groupIds): expected ["file"], actual ["blob"]`,
+		`Connections[psc].Status (properties.privateLinkServiceConnections[0].properties.privateLinkServiceConnectionState.status): expected "Pending", actual "Approved"`,
+		`Connections (properties.privateLinkServiceConnections[*].name): expected "psc-queue", actual <none>`,
+	}, lines)
+}
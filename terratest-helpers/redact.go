@@ -0,0 +1,376 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/require"
+)
+
+// Value replacing the sensitive values
+const redactedValue = "<redacted>"
+
+// Prefix of the masked environment variables in the saved options (see redactedEnvVar)
+const redactedEnvVarPrefix = "<redacted:"
+
+// Environment variables holding secrets (eg. ARM_CLIENT_SECRET or ARM_OIDC_TOKEN)
+var secretEnvVarRegexp = regexp.MustCompile(`(?i)(SECRET|TOKEN|PASSWORD|KEY)$`)
+
+// Struct tag marking a test data field as sensitive (eg. `sensitive:"true"`). See MarkSensitiveFields.
+const sensitiveTag = "sensitive"
+
+// Prefix of the environment variables Terraform reads input variables from
+const tfVarPrefix = "TF_VAR_"
+
+// Values and Vars keys marked sensitive, and the sensitive environment variables of the options built by this process
+// (by TerraformDir then name) so they can be restored when the options are loaded
+var sensitive = struct {
+	sync.RWMutex
+	values  map[string]bool
+	varKeys map[string]bool
+	envVars map[string]map[string]string
+}{values: map[string]bool{}, varKeys: map[string]bool{}, envVars: map[string]map[string]string{}}
+
+// Marks the values as sensitive: they are masked in the saved options, stage logs, reports and artifacts
+func MarkSensitive(values ...string) {
+	sensitive.Lock()
+	defer sensitive.Unlock()
+	for _, value := range values {
+		if value != "" {
+			sensitive.values[value] = true
+		}
+	}
+}
+
+// Marks the Vars keys as sensitive (eg. "ddos_id"). NewTerraformOptions passes sensitive Vars to Terraform as TF_VAR_
+// environment variables rather than on the command line, and their values are masked like the ones of MarkSensitive.
+func MarkSensitiveVars(keys ...string) {
+	sensitive.Lock()
+	defer sensitive.Unlock()
+	for _, key := range keys {
+		sensitive.varKeys[key] = true
+	}
+}
+
+// Marks the values of the test data struct's fields tagged with `sensitive:"true"` as sensitive (including the
+// strings of slice and map fields). Vars set to one of those values are passed to Terraform as TF_VAR_ environment
+// variables.
+func MarkSensitiveFields(testData interface{}) {
+	value := reflect.Indirect(reflect.ValueOf(testData))
+	if value.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < value.NumField(); i++ {
+		if tag := value.Type().Field(i).Tag.Get(sensitiveTag); tag == "true" {
+			MarkSensitive(stringValues(value.Field(i))...)
+		}
+	}
+}
+
+// Returns the strings contained in the value (read through reflection, so unexported fields are supported)
+func stringValues(value reflect.Value) []string {
+	switch value.Kind() {
+	case reflect.String:
+		return []string{value.String()}
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return stringValues(value.Elem())
+	case reflect.Slice, reflect.Array:
+		values := []string{}
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, stringValues(value.Index(i))...)
+		}
+		return values
+	case reflect.Map:
+		values := []string{}
+		iter := value.MapRange()
+		for iter.Next() {
+			values = append(values, stringValues(iter.Value())...)
+		}
+		return values
+	}
+	return nil
+}
+
+// Replaces the sensitive values in s with <redacted>
+func RedactString(s string) string {
+	sensitive.RLock()
+	values := make([]string, 0, len(sensitive.values))
+	for value := range sensitive.values {
+		values = append(values, value)
+	}
+	sensitive.RUnlock()
+
+	// Longest first, so a value containing another one is fully masked
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, redactedValue)
+	}
+	return s
+}
+
+// Returns a copy of the decoded JSON-like value with the sensitive values masked
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return RedactString(v)
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactValue(item)
+		}
+		return redacted
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			redacted[key] = redactValue(item)
+		}
+		return redacted
+	}
+	return value
+}
+
+// Returns true if the Var is sensitive: its key was marked with MarkSensitiveVars, or one of its strings was marked
+// sensitive
+func isSensitiveVar(key string, value interface{}) bool {
+	sensitive.RLock()
+	defer sensitive.RUnlock()
+	if sensitive.varKeys[key] {
+		return true
+	}
+	for _, s := range stringValues(reflect.ValueOf(value)) {
+		if sensitive.values[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the environment variable holds a secret (a sensitive Var or a credential, eg. ARM_CLIENT_SECRET)
+func isSensitiveEnvVar(key string, value string) bool {
+	if value == "" {
+		return false
+	}
+	if secretEnvVarRegexp.MatchString(key) {
+		return true
+	}
+	sensitive.RLock()
+	defer sensitive.RUnlock()
+	if strings.HasPrefix(key, tfVarPrefix) && sensitive.varKeys[strings.TrimPrefix(key, tfVarPrefix)] {
+		return true
+	}
+	return sensitive.values[value]
+}
+
+// Moves the sensitive Vars of the options (see MarkSensitiveVars) to TF_VAR_ environment variables, so they are not
+// written to the saved options or the command lines, and marks the secrets of the environment variables sensitive
+func configureSensitiveVars(t *testing.T, options *terraform.Options) {
+	if options.EnvVars == nil {
+		options.EnvVars = map[string]string{}
+	}
+	for key, value := range options.Vars {
+		if !isSensitiveVar(key, value) {
+			continue
+		}
+		envValue, ok := value.(string)
+		if !ok {
+			// Complex values are parsed as HCL, which accepts JSON
+			encoded, err := json.Marshal(value)
+			require.NoError(t, err)
+			envValue = string(encoded)
+		}
+		MarkSensitive(stringValues(reflect.ValueOf(value))...)
+		options.EnvVars[tfVarPrefix+key] = envValue
+		delete(options.Vars, key)
+	}
+
+	envVars := map[string]string{}
+	for key, value := range options.EnvVars {
+		if isSensitiveEnvVar(key, value) {
+			MarkSensitive(value)
+			envVars[key] = value
+		}
+	}
+	sensitive.Lock()
+	sensitive.envVars[options.TerraformDir] = envVars
+	sensitive.Unlock()
+}
+
+// Returns a copy of the options with the sensitive Vars and environment variables masked
+func redactTerraformOptions(options *terraform.Options) *terraform.Options {
+	redacted := *options
+	redacted.EnvVars = map[string]string{}
+	for key, value := range options.EnvVars {
+		if isSensitiveEnvVar(key, value) {
+			value = redactedEnvVar(value)
+		} else {
+			value = RedactString(value)
+		}
+		redacted.EnvVars[key] = value
+	}
+	if options.Vars != nil {
+		redacted.Vars = map[string]interface{}{}
+		for key, value := range options.Vars {
+			normalized, err := normalizeJSON(value)
+			if err != nil {
+				normalized = value
+			}
+			redacted.Vars[key] = redactValue(normalized)
+		}
+	}
+	redacted.Logger = nil
+	return &redacted
+}
+
+// Saves the options (with the sensitive values masked) to terraformOptionsDir, where LoadTerraformOptions (or
+// ts.LoadTerraformOptions) can load them
+func SaveTerraformOptions(t *testing.T, testRootDir string, terraformOptionsDir string, options *terraform.Options) {
+	path := ts.FormatTestDataPath(testRootDir+terraformOptionsDir, "TerraformOptions.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, writeJSON(path, redactTerraformOptions(options)))
+}
+
+// Key of the fingerprints of the masked environment variables (see redactedEnvVar). Defaults to a random key saved in
+// <user cache dir>/terratest/redaction.key, so a later run (eg. with SKIP_setup_ and SKIP_deploy_ set) on the same
+// machine can restore the options saved by an earlier one. Set it to share the key between the jobs of a CI pipeline.
+const RedactionKeyEnv = "TERRATEST_REDACTION_KEY"
+
+// Name of the file holding the default redaction key in the user cache directory
+const redactionKeyFileName = "redaction.key"
+
+// Default redaction key, loaded (or created) once per process
+var defaultRedactionKey struct {
+	once sync.Once
+	key  []byte
+}
+
+// Returns the masked value of a sensitive environment variable in the saved options. It contains an HMAC of the value
+// keyed with the redaction key (see RedactionKeyEnv), which is never saved with the options or artifacts, so the value
+// can be restored but the saved options cannot be used to guess or confirm it (eg. a resource ID).
+func redactedEnvVar(value string) string {
+	mac := hmac.New(sha256.New, getRedactionKey())
+	mac.Write([]byte(value))
+	return fmt.Sprintf("%s%s>", redactedEnvVarPrefix, hex.EncodeToString(mac.Sum(nil))[:16])
+}
+
+// Returns the redaction key set in RedactionKeyEnv, falling back to the key of the user cache directory (or to a random
+// key of the process when it cannot be read or created, in which case a later run restores the options from the
+// environment)
+func getRedactionKey() []byte {
+	if key := os.Getenv(RedactionKeyEnv); key != "" {
+		return []byte(key)
+	}
+	defaultRedactionKey.once.Do(func() {
+		if userCacheDir, err := os.UserCacheDir(); err == nil {
+			key, err := loadRedactionKeyE(filepath.Join(userCacheDir, "terratest", redactionKeyFileName))
+			if err == nil {
+				defaultRedactionKey.key = key
+				return
+			}
+		}
+		defaultRedactionKey.key = newRedactionKey()
+	})
+	return defaultRedactionKey.key
+}
+
+// Reads the redaction key of the file, creating the file (only readable by the user) with a random key if it does not
+// exist
+func loadRedactionKeyE(path string) ([]byte, error) {
+	if key, err := os.ReadFile(path); err == nil && len(key) > 0 {
+		return key, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		// Created by another test binary in the meantime
+		return os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key := []byte(hex.EncodeToString(newRedactionKey()))
+	_, err = file.Write(key)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Returns a random redaction key
+func newRedactionKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("unable to generate the redaction key: %v", err))
+	}
+	return key
+}
+
+// Restores the masked environment variables of loaded options. Each one is restored from the first value matching its
+// fingerprint among the options built by this process, the selected credential provider, the values marked sensitive
+// and the environment, falling back to the environment (eg. TF_VAR_ddos_id set explicitly). An error is returned if
+// any of them cannot be restored, so a masked value is never passed to Terraform.
+func restoreSensitiveEnvVars(options *terraform.Options) error {
+	sensitive.RLock()
+	candidates := map[string][]string{}
+	for key, value := range sensitive.envVars[options.TerraformDir] {
+		candidates[key] = append(candidates[key], value)
+	}
+	values := []string{}
+	for value := range sensitive.values {
+		values = append(values, value)
+	}
+	sensitive.RUnlock()
+	if provider, err := GetCredentialProviderE(); err == nil {
+		for key, value := range provider.EnvVars() {
+			candidates[key] = append(candidates[key], value)
+		}
+	}
+
+	missing := []string{}
+	for key, masked := range options.EnvVars {
+		if !strings.HasPrefix(masked, redactedEnvVarPrefix) {
+			continue
+		}
+		restored, ok := "", false
+		for _, candidate := range append(append(candidates[key], values...), os.Getenv(key)) {
+			if candidate != "" && redactedEnvVar(candidate) == masked {
+				restored, ok = candidate, true
+				break
+			}
+		}
+		if !ok {
+			restored, ok = os.LookupEnv(key)
+		}
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		options.EnvVars[key] = restored
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("the sensitive environment variables %s of %s are not set", strings.Join(missing, ", "), options.TerraformDir)
+	}
+	return nil
+}
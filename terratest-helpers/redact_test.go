@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type redactTestData struct {
+	vNetName    string
+	ddosID      string   `sensitive:"true"`
+	workspaceID []string `sensitive:"true"`
+}
+
+func TestRedactString(t *testing.T) {
+	MarkSensitiveFields(redactTestData{
+		vNetName:    "vnet-redact",
+		ddosID:      "/subscriptions/redact/ddosProtectionPlans/ddos",
+		workspaceID: []string{"/subscriptions/redact/workspaces/la"},
+	})
+	MarkSensitive("/subscriptions/redact")
+
+	assert.Equal(t, "vnet-redact uses <redacted> and <redacted>",
+		RedactString("vnet-redact uses /subscriptions/redact/ddosProtectionPlans/ddos and /subscriptions/redact/workspaces/la"))
+	assert.Equal(t, "<redacted>/resourceGroups/rg", RedactString("/subscriptions/redact/resourceGroups/rg"))
+}
+
+func TestSensitiveVars(t *testing.T) {
+	testRootDir := t.TempDir() + "/"
+	MarkSensitiveVars("log_analytics_id")
+	MarkSensitive("/ddosProtectionPlans/sensitive-var")
+
+	options := &terraform.Options{
+		TerraformDir: testRootDir + "module/",
+		Vars: map[string]interface{}{
+			"vnet_name":        "vnet",
+			"ddos_id":          "/ddosProtectionPlans/sensitive-var",
+			"log_analytics_id": "/workspaces/sensitive-var",
+			"vnet_cidr":        []string{"10.0.0.0/16"},
+		},
+		EnvVars: map[string]string{"ARM_CLIENT_SECRET": "sensitive-var-secret", "ARM_USE_CLI": "false"},
+	}
+	configureSensitiveVars(t, options)
+	assert.Equal(t, map[string]interface{}{"vnet_name": "vnet", "vnet_cidr": []string{"10.0.0.0/16"}}, options.Vars)
+	assert.Equal(t, "/ddosProtectionPlans/sensitive-var", options.EnvVars["TF_VAR_ddos_id"])
+	assert.Equal(t, "/workspaces/sensitive-var", options.EnvVars["TF_VAR_log_analytics_id"])
+
+	SaveTerraformOptions(t, testRootDir, "moduleTerraformOptions/", options)
+	saved, err := os.ReadFile(ts.FormatTestDataPath(testRootDir+"moduleTerraformOptions/", "TerraformOptions.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(saved), "sensitive-var")
+	assert.Contains(t, string(saved), `"TF_VAR_ddos_id": "<redacted:`)
+
+	loaded := LoadTerraformOptions(t, testRootDir, "moduleTerraformOptions/")
+	assert.Equal(t, options.EnvVars, loaded.EnvVars)
+	assert.Equal(t, "false", loaded.EnvVars["ARM_USE_CLI"])
+}
+
+func TestRestoreSensitiveEnvVars(t *testing.T) {
+	t.Setenv("TF_VAR_env_id", "/workspaces/from-env")
+	t.Setenv("TF_VAR_explicit_id", "/workspaces/explicit")
+	MarkSensitive("/workspaces/marked")
+	options := &terraform.Options{
+		TerraformDir: "TestRestore/module/",
+		EnvVars: map[string]string{
+			"TF_VAR_env_id":      redactedEnvVar("/workspaces/from-env"),
+			"TF_VAR_marked_id":   redactedEnvVar("/workspaces/marked"),
+			"TF_VAR_explicit_id": redactedEnvVar("/workspaces/changed"),
+			"TF_VAR_missing_id":  redactedEnvVar("/workspaces/missing"),
+		},
+	}
+
+	err := restoreSensitiveEnvVars(options)
+	assert.EqualError(t, err, "the sensitive environment variables TF_VAR_missing_id of TestRestore/module/ are not set")
+	assert.Equal(t, "/workspaces/from-env", options.EnvVars["TF_VAR_env_id"])
+	assert.Equal(t, "/workspaces/marked", options.EnvVars["TF_VAR_marked_id"])
+	assert.Equal(t, "/workspaces/explicit", options.EnvVars["TF_VAR_explicit_id"])
+}
+
+func TestRedactedEnvVarIsKeyed(t *testing.T) {
+	value := "/subscriptions/redact/resourceGroups/rg/providers/Microsoft.Network/ddosProtectionPlans/ddos"
+	digest := sha256.Sum256([]byte(value))
+	assert.Equal(t, redactedEnvVar(value), redactedEnvVar(value))
+	assert.NotContains(t, redactedEnvVar(value), hex.EncodeToString(digest[:])[:16])
+}
+
+func TestRestoreSensitiveEnvVarsOfAnEarlierProcess(t *testing.T) {
+	t.Setenv(RedactionKeyEnv, "shared-key")
+	t.Setenv("TF_VAR_ddos_id", "/ddosProtectionPlans/from-env")
+	MarkSensitive("/ddosProtectionPlans/earlier")
+	// Fingerprint saved by an earlier process with the same key
+	mac := hmac.New(sha256.New, []byte("shared-key"))
+	mac.Write([]byte("/ddosProtectionPlans/earlier"))
+	options := &terraform.Options{
+		TerraformDir: "TestRestoreEarlier/module/",
+		EnvVars:      map[string]string{"TF_VAR_ddos_id": redactedEnvVarPrefix + hex.EncodeToString(mac.Sum(nil))[:16] + ">"},
+	}
+	assert.Equal(t, options.EnvVars["TF_VAR_ddos_id"], redactedEnvVar("/ddosProtectionPlans/earlier"))
+
+	require.NoError(t, restoreSensitiveEnvVars(options))
+	assert.Equal(t, "/ddosProtectionPlans/earlier", options.EnvVars["TF_VAR_ddos_id"])
+}
+
+func TestLoadRedactionKeyE(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terratest", redactionKeyFileName)
+
+	key, err := loadRedactionKeyE(path)
+	require.NoError(t, err)
+	assert.NotEmpty(t, key)
+	// The key is kept for the later runs
+	loaded, err := loadRedactionKeyE(path)
+	require.NoError(t, err)
+	assert.Equal(t, key, loaded)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
+
+func TestLoadTerraformOptionsEWithMaskedValue(t *testing.T) {
+	testRootDir := t.TempDir() + "/"
+	MarkSensitive("/workspaces/masked-value")
+	options := &terraform.Options{
+		TerraformDir: testRootDir + "module/",
+		EnvVars:      map[string]string{"TF_VAR_masked_id": "/workspaces/masked-value"},
+	}
+	SaveTerraformOptions(t, testRootDir, "moduleTerraformOptions/", options)
+	// Saved by another process with another key
+	t.Setenv(RedactionKeyEnv, "another-key")
+
+	loaded, err := LoadTerraformOptionsE(t, testRootDir, "moduleTerraformOptions/")
+	assert.Nil(t, loaded)
+	assert.ErrorContains(t, err, "TF_VAR_masked_id")
+}
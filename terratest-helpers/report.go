@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Directory the JUnit XML (<TestName>.xml) and JSON (<TestName>.json) reports are written to. Defaults to test-reports
+// (outside of the testRootDir, which is removed by the teardown stage).
+const ReportDirEnv = "TERRATEST_REPORT_DIR"
+
+const defaultReportDir = "test-reports"
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string         `xml:"classname,attr"`
+	Name      string         `xml:"name,attr"`
+	Time      string         `xml:"time,attr"`
+	Failure   *junitFailure  `xml:"failure,omitempty"`
+	Skipped   *struct{}      `xml:"skipped,omitempty"`
+	SystemOut *junitTextNode `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTextNode struct {
+	Text string `xml:",chardata"`
+}
+
+type jsonTestReport struct {
+	Test     string         `json:"test"`
+	Status   string         `json:"status"`
+	Start    time.Time      `json:"start"`
+	End      time.Time      `json:"end"`
+	Duration float64        `json:"durationSeconds"`
+	Stages   []*stageRecord `json:"stages"`
+}
+
+// Returns the absolute path of the report directory, creating it if needed
+func getReportDirE() (string, error) {
+	dir := os.Getenv(ReportDirEnv)
+	if dir == "" {
+		dir = defaultReportDir
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// Writes the JUnit XML and JSON reports of the test
+func writeTestReports(record *testRecord) error {
+	record.mutex.Lock()
+	defer record.mutex.Unlock()
+
+	dir, err := getReportDirE()
+	if err != nil {
+		return err
+	}
+	end := time.Now()
+	fileName := strings.NewReplacer("/", "_", "\\", "_").Replace(record.name)
+
+	report := jsonTestReport{Test: record.name, Status: stagePassed, Start: record.start, End: end, Duration: end.Sub(record.start).Seconds(), Stages: record.stages}
+	suite := junitTestSuite{Name: record.name, Time: formatSeconds(report.Duration), Timestamp: record.start.Format(time.RFC3339)}
+	for _, stage := range record.stages {
+		for i, failure := range stage.Failures {
+			stage.Failures[i] = RedactString(failure)
+		}
+		testCase := junitTestCase{ClassName: record.name, Name: stage.Name, Time: formatSeconds(stage.Duration)}
+		switch stage.Status {
+		case stageFailed:
+			report.Status = stageFailed
+			suite.Failures++
+			testCase.Failure = &junitFailure{Message: stage.Failures[0], Text: strings.Join(stage.Failures, "\n")}
+		case stageSkipped:
+			suite.Skipped++
+			testCase.Skipped = &struct{}{}
+		}
+		lines := []string{}
+		if stage.QueueWait > 0 {
+			lines = append(lines, fmt.Sprintf("waited %ss for deploy slots", formatSeconds(stage.QueueWait)))
+		}
+		for _, command := range stage.Terraform {
+			lines = append(lines, fmt.Sprintf("terraform %s in %s: exit code %d", command.Command, command.Dir, command.ExitCode))
+		}
+		if len(lines) > 0 {
+			testCase.SystemOut = &junitTextNode{Text: strings.Join(lines, "\n")}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(suite.TestCases)
+
+	encodedXML, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, fileName+".xml"), append([]byte(xml.Header), encodedXML...), 0644); err != nil {
+		return err
+	}
+	encodedJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, fileName+".json"), encodedJSON, 0644)
+}
+
+func formatSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
@@ -0,0 +1,311 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// API version used to list the resource types (and their API versions) of a resource provider
+const providersAPIVersion = "2021-04-01"
+
+// Name and version reported by the ARM client's telemetry
+const (
+	armClientModuleName    = "terratest-helpers"
+	armClientModuleVersion = "v0.1.0"
+)
+
+// API versions already resolved by this process, keyed by lower case <namespace>/<type>
+var apiVersions sync.Map
+
+// A resource fetched from Azure Resource Manager. The JSON document returned by ARM is kept as is, so any field
+// (including the ones not covered by the Terratest azure module) can be asserted with a JSONPath-style path.
+type AzureResource struct {
+	ID         string
+	APIVersion string
+	// The JSON document returned by ARM (id, name, type, location, tags, properties, ...)
+	Body map[string]interface{}
+}
+
+// Gets the resource through its full resource ID (eg. the subnet_id output), failing the test on error. The resource is
+// saved with the artifacts of the test if it fails (see SaveArtifacts).
+func GetAzureResource(t *testing.T, resourceID string) *AzureResource {
+	resource, err := GetAzureResourceE(resourceID)
+	if err != nil {
+		recordError(t, err.Error())
+	}
+	require.NoError(t, err)
+	recordFetchedResource(t, resource)
+	return resource
+}
+
+// Gets the resource through its full resource ID. The API version is resolved from the resource types of the
+// resource provider (the latest stable version is used). Throttled and failed lookups are retried until the resource
+// exists (see RetryARME and DefaultRetryOptions).
+func GetAzureResourceE(resourceID string) (*AzureResource, error) {
+	return RetryARME(DefaultRetryOptions, resourceID, func() (*AzureResource, error) {
+		return getAzureResourceE(resourceID)
+	})
+}
+
+// Gets the resource through its full resource ID, without retries
+func getAzureResourceE(resourceID string) (*AzureResource, error) {
+	ctx := context.Background()
+	id, err := arm.ParseResourceID(resourceID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource ID %q: %w", resourceID, err)
+	}
+
+	client, err := newARMClient()
+	if err != nil {
+		return nil, err
+	}
+
+	apiVersion, err := getAPIVersionE(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{}
+	if err := armGet(ctx, client, resourceID, apiVersion, &body); err != nil {
+		return nil, err
+	}
+	return &AzureResource{ID: resourceID, APIVersion: apiVersion, Body: body}, nil
+}
+
+// Creates an ARM client for the selected cloud (see GetAzureCloudE), authenticated with the selected credential
+// provider (see GetCredentialProviderE)
+func newARMClient() (*arm.Client, error) {
+	azureCloud, err := GetAzureCloudE()
+	if err != nil {
+		return nil, err
+	}
+	options := azcore.ClientOptions{Cloud: azureCloud.Configuration, Transport: armTransport}
+	cred, err := newCredential(options)
+	if err != nil {
+		return nil, err
+	}
+	// The lookups are retried by RetryARME, which also waits for the resource to exist
+	options.Retry = policy.RetryOptions{MaxRetries: -1}
+	return arm.NewClient(armClientModuleName, armClientModuleVersion, cred, &arm.ClientOptions{ClientOptions: options})
+}
+
+// Sends a GET request for the ARM path and decodes the JSON response into v
+func armGet(ctx context.Context, client *arm.Client, path string, apiVersion string, v interface{}) error {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, runtime.JoinPaths(client.Endpoint(), path))
+	if err != nil {
+		return err
+	}
+	query := req.Raw().URL.Query()
+	query.Set("api-version", apiVersion)
+	req.Raw().URL.RawQuery = query.Encode()
+	req.Raw().Header.Set("Accept", "application/json")
+
+	resp, err := client.Pipeline().Do(req)
+	if err != nil {
+		return err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return runtime.NewResponseError(resp)
+	}
+	return runtime.UnmarshalAsJSON(resp, v)
+}
+
+// Returns the latest stable API version of the resource's type (falling back to the latest preview version)
+func getAPIVersionE(ctx context.Context, client *arm.Client, id *arm.ResourceID) (string, error) {
+	namespace, resourceType := id.ResourceType.Namespace, id.ResourceType.Type
+	key := strings.ToLower(namespace + "/" + resourceType)
+	if apiVersion, ok := apiVersions.Load(key); ok {
+		return apiVersion.(string), nil
+	}
+
+	subscriptionID := id.SubscriptionID
+	if subscriptionID == "" {
+		return "", fmt.Errorf("unable to resolve the API version of %s: the resource ID has no subscription", id.String())
+	}
+
+	provider := struct {
+		ResourceTypes []struct {
+			ResourceType string   `json:"resourceType"`
+			APIVersions  []string `json:"apiVersions"`
+		} `json:"resourceTypes"`
+	}{}
+	path := fmt.Sprintf("/subscriptions/%s/providers/%s", subscriptionID, namespace)
+	if err := armGet(ctx, client, path, providersAPIVersion, &provider); err != nil {
+		return "", fmt.Errorf("unable to get the resource types of %s: %w", namespace, err)
+	}
+
+	for _, rt := range provider.ResourceTypes {
+		if !strings.EqualFold(rt.ResourceType, resourceType) {
+			continue
+		}
+		apiVersion := latestAPIVersion(rt.APIVersions)
+		if apiVersion == "" {
+			break
+		}
+		apiVersions.Store(key, apiVersion)
+		return apiVersion, nil
+	}
+	return "", fmt.Errorf("no API version found for %s/%s", namespace, resourceType)
+}
+
+// Returns the latest non preview API version (or the latest preview if there are no stable versions)
+func latestAPIVersion(versions []string) string {
+	sorted := append([]string{}, versions...)
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+	for _, v := range sorted {
+		if !strings.Contains(strings.ToLower(v), "preview") {
+			return v
+		}
+	}
+	if len(sorted) > 0 {
+		return sorted[0]
+	}
+	return ""
+}
+
+// Returns the value at the JSONPath-style path (eg. "properties.addressPrefix", "$.properties.serviceEndpoints[0]
+// .service", "tags['cost-center']"). A [*] wildcard returns the list of values matched by the rest of the path (eg.
+// "properties.serviceEndpoints[*].service").
+func (r *AzureResource) Get(path string) (interface{}, error) {
+	segments, err := parsePropertyPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return getPropertyPath(r.Body, segments, path)
+}
+
+// Asserts that the value at the path equals expected. The expected value is compared with its JSON representation, so
+// Go values (eg. []string or int) can be compared with the decoded JSON values.
+func AssertProperty(t *testing.T, resource *AzureResource, path string, expected interface{}, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	actual, err := resource.Get(path)
+	if err != nil {
+		recordError(t, fmt.Sprintf("unable to get %s of %s: %v", path, resource.ID, err))
+		return assert.Fail(t, fmt.Sprintf("Unable to get %s of %s: %v", path, resource.ID, err), msgAndArgs...)
+	}
+	normalized, err := normalizeJSON(expected)
+	if err != nil {
+		return assert.Fail(t, fmt.Sprintf("Unable to compare %s with %#v: %v", path, expected, err), msgAndArgs...)
+	}
+	if len(msgAndArgs) == 0 {
+		msgAndArgs = []interface{}{"%s of %s", path, resource.ID}
+	}
+	if !assert.Equal(t, normalized, actual, msgAndArgs...) {
+		recordError(t, fmt.Sprintf("%s of %s: expected %v, actual %v", path, resource.ID, normalized, actual))
+		return false
+	}
+	return true
+}
+
+// Converts the value to the types produced when decoding JSON (map[string]interface{}, []interface{}, float64, ...)
+func normalizeJSON(value interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	err = json.Unmarshal(encoded, &normalized)
+	return normalized, err
+}
+
+// A segment of a property path: a map key, a list index or a [*] wildcard
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parsePropertyPath(path string) ([]pathSegment, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	segments := []pathSegment{}
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", path)
+			}
+			inner := rest[1:end]
+			switch {
+			case inner == "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: invalid index [%s]", path, inner)
+				}
+				segments = append(segments, pathSegment{index: index, isIndex: true})
+			}
+			rest = strings.TrimPrefix(rest[end+1:], ".")
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+			segments = append(segments, pathSegment{key: rest[:end]})
+			rest = strings.TrimPrefix(rest[end:], ".")
+		}
+	}
+	return segments, nil
+}
+
+func getPropertyPath(value interface{}, segments []pathSegment, path string) (interface{}, error) {
+	for i, segment := range segments {
+		switch {
+		case segment.wildcard:
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: [*] applied to a %T", path, value)
+			}
+			values := []interface{}{}
+			for _, item := range list {
+				v, err := getPropertyPath(item, segments[i+1:], path)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, v)
+			}
+			return values, nil
+		case segment.isIndex:
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: [%d] applied to a %T", path, segment.index, value)
+			}
+			if segment.index < 0 || segment.index >= len(list) {
+				return nil, fmt.Errorf("%s: index %d out of range (%d items)", path, segment.index, len(list))
+			}
+			value = list[segment.index]
+		default:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: %q applied to a %T", path, segment.key, value)
+			}
+			v, ok := object[segment.key]
+			if !ok {
+				return nil, fmt.Errorf("%s: %q not found", path, segment.key)
+			}
+			value = v
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const subnetJSON = `{
+  "id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-snet/providers/Microsoft.Network/virtualNetworks/vnet/subnets/snet",
+  "name": "snet",
+  "properties": {
+    "addressPrefixes": ["10.0.0.0/24"],
+    "privateEndpointNetworkPolicies": "Disabled",
+    "serviceEndpoints": [
+      {"service": "Microsoft.Storage", "locations": ["canadacentral"]},
+      {"service": "Microsoft.Sql", "locations": ["canadacentral"]}
+    ]
+  },
+  "tags": {"cost-center": "1234"}
+}`
+
+func newTestResource(t *testing.T, body string) *AzureResource {
+	resource := &AzureResource{ID: "snet"}
+	require.NoError(t, json.Unmarshal([]byte(body), &resource.Body))
+	return resource
+}
+
+func TestAzureResourceGet(t *testing.T) {
+	resource := newTestResource(t, subnetJSON)
+
+	tests := map[string]interface{}{
+		"name": "snet",
+		"$.properties.privateEndpointNetworkPolicies": "Disabled",
+		"properties.addressPrefixes[0]":               "10.0.0.0/24",
+		"properties.serviceEndpoints[1].service":      "Microsoft.Sql",
+		"properties.serviceEndpoints[*].service":      []interface{}{"Microsoft.Storage", "Microsoft.Sql"},
+		"tags['cost-center']":                         "1234",
+	}
+	for path, expected := range tests {
+		actual, err := resource.Get(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, actual, path)
+	}
+
+	for _, path := range []string{"properties.missing", "properties.serviceEndpoints[2]", "name[0]", "properties[", "properties..name"} {
+		_, err := resource.Get(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestAssertProperty(t *testing.T) {
+	resource := newTestResource(t, subnetJSON)
+
+	AssertProperty(t, resource, "properties.privateEndpointNetworkPolicies", "Disabled")
+	AssertProperty(t, resource, "properties.addressPrefixes", []string{"10.0.0.0/24"})
+	AssertProperty(t, resource, "properties.serviceEndpoints[0]", map[string]interface{}{
+		"service":   "Microsoft.Storage",
+		"locations": []string{"canadacentral"},
+	})
+}
+
+func TestLatestAPIVersion(t *testing.T) {
+	assert.Equal(t, "2023-05-01", latestAPIVersion([]string{"2022-01-01", "2023-06-01-preview", "2023-05-01", "2018-02-01"}))
+	assert.Equal(t, "2023-06-01-preview", latestAPIVersion([]string{"2023-06-01-preview", "2022-01-01-preview"}))
+	assert.Equal(t, "", latestAPIVersion(nil))
+}
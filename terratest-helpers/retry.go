@@ -0,0 +1,204 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/go-autorest/autorest"
+	autorestazure "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/stretchr/testify/require"
+)
+
+// Settings of the retried Azure Resource Manager lookups (see RetryARME)
+type RetryOptions struct {
+	// Maximum time spent retrying a lookup
+	Timeout time.Duration
+	// Delay before the first retry, doubled on each retry up to MaxDelay. A Retry-After header returned by ARM takes
+	// precedence. A delay that is not positive defaults to defaultRetryBaseDelay, and a MaxDelay below the BaseDelay is
+	// raised to it, so the lookups never spin.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Retry 404 Not Found responses until the resource appears, as resources created by Terraform may not be visible
+	// to every ARM replica right away
+	WaitForExistence bool
+}
+
+// Settings used by the validate helpers (eg. GetAzureResource or GetSubnet)
+var DefaultRetryOptions = RetryOptions{
+	Timeout:          5 * time.Minute,
+	BaseDelay:        2 * time.Second,
+	MaxDelay:         30 * time.Second,
+	WaitForExistence: true,
+}
+
+// Delay before the first retry when the RetryOptions have no positive BaseDelay
+const defaultRetryBaseDelay = time.Second
+
+// Headers holding the delay requested by ARM before the next request (in the order they are checked)
+var retryAfterHeaders = []string{"retry-after-ms", "x-ms-retry-after-ms", "Retry-After"}
+
+// Error of a failed ARM lookup, including the IDs needed to trace the request with Azure support
+type ARMError struct {
+	// Description of the lookup (eg. the resource ID)
+	Description string
+	// HTTP status code of the last response (0 if no response was received)
+	StatusCode int
+	// ARM error code of the last response (eg. ResourceNotFound)
+	ErrorCode     string
+	CorrelationID string
+	RequestID     string
+	Attempts      int
+	Err           error
+}
+
+func (e *ARMError) Error() string {
+	details := []string{}
+	if e.StatusCode != 0 {
+		details = append(details, fmt.Sprintf("status %d", e.StatusCode))
+	}
+	if e.ErrorCode != "" {
+		details = append(details, "code "+e.ErrorCode)
+	}
+	if e.CorrelationID != "" {
+		details = append(details, "correlation ID "+e.CorrelationID)
+	}
+	if e.RequestID != "" {
+		details = append(details, "request ID "+e.RequestID)
+	}
+	message := fmt.Sprintf("unable to get %s after %d attempt(s)", e.Description, e.Attempts)
+	if len(details) > 0 {
+		message += " (" + strings.Join(details, ", ") + ")"
+	}
+	return fmt.Sprintf("%s: %v", message, e.Err)
+}
+
+func (e *ARMError) Unwrap() error {
+	return e.Err
+}
+
+// Returns true if the lookup can be retried: ARM throttled it (429), failed with a server error (5xx), or did not find
+// the resource yet (404, with WaitForExistence)
+func (e *ARMError) retryable(options RetryOptions) bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode >= http.StatusInternalServerError:
+		return true
+	case e.StatusCode == http.StatusNotFound:
+		return options.WaitForExistence
+	}
+	return false
+}
+
+// Returns the ARMError of err, reading the status code and headers of the response of the azcore SDK (used by
+// GetAzureResourceE) and autorest SDK (used by the Terratest azure module) errors
+func newARMError(description string, attempts int, err error) (*ARMError, *http.Response) {
+	armErr := &ARMError{Description: description, Attempts: attempts, Err: err}
+	var resp *http.Response
+
+	var responseErr *azcore.ResponseError
+	var requestErr *autorestazure.RequestError
+	var detailedErr autorest.DetailedError
+	switch {
+	case errors.As(err, &responseErr):
+		armErr.StatusCode, armErr.ErrorCode, resp = responseErr.StatusCode, responseErr.ErrorCode, responseErr.RawResponse
+	case errors.As(err, &requestErr):
+		resp = requestErr.Response
+		if requestErr.ServiceError != nil {
+			armErr.ErrorCode = requestErr.ServiceError.Code
+		}
+	case errors.As(err, &detailedErr):
+		resp = detailedErr.Response
+		if statusCode, ok := detailedErr.StatusCode.(int); ok {
+			armErr.StatusCode = statusCode
+		}
+		if requestErr, ok := detailedErr.Original.(*autorestazure.RequestError); ok && requestErr.ServiceError != nil {
+			armErr.ErrorCode = requestErr.ServiceError.Code
+		}
+	}
+	if resp != nil {
+		armErr.StatusCode = resp.StatusCode
+		armErr.CorrelationID = resp.Header.Get("x-ms-correlation-request-id")
+		armErr.RequestID = resp.Header.Get("x-ms-request-id")
+	}
+	return armErr, resp
+}
+
+// Returns the delay requested by the Retry-After headers of the response (0 if there are none)
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	for _, header := range retryAfterHeaders {
+		value := resp.Header.Get(header)
+		if value == "" {
+			continue
+		}
+		if strings.HasSuffix(header, "-ms") {
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				return time.Duration(ms) * time.Millisecond
+			}
+			continue
+		}
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			if delay := time.Until(date); delay > 0 {
+				return delay
+			}
+		}
+	}
+	return 0
+}
+
+// Calls get until it succeeds, retrying throttled (429) and failed (5xx) ARM lookups and, with WaitForExistence, the
+// ones that did not find the resource yet (404). Each retry waits for the Retry-After delay returned by ARM, or an
+// exponential backoff. The returned error is an *ARMError.
+func RetryARME[T any](options RetryOptions, description string, get func() (T, error)) (T, error) {
+	deadline := time.Now().Add(options.Timeout)
+	delay := options.BaseDelay
+	if delay <= 0 {
+		delay = defaultRetryBaseDelay
+	}
+	if options.MaxDelay < delay {
+		options.MaxDelay = delay
+	}
+	for attempt := 1; ; attempt++ {
+		value, err := get()
+		if err == nil {
+			return value, nil
+		}
+		armErr, resp := newARMError(description, attempt, err)
+		if !armErr.retryable(options) {
+			return value, armErr
+		}
+
+		wait := retryAfter(resp)
+		if wait == 0 {
+			wait = delay
+			delay *= 2
+			if delay > options.MaxDelay {
+				delay = options.MaxDelay
+			}
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return value, armErr
+		}
+		time.Sleep(wait)
+	}
+}
+
+// Calls get until it succeeds (see RetryARME) with the DefaultRetryOptions, failing the test on error
+func RetryARM[T any](t *testing.T, description string, get func() (T, error)) T {
+	value, err := RetryARME(DefaultRetryOptions, description, get)
+	if err != nil {
+		recordError(t, err.Error())
+	}
+	require.NoError(t, err)
+	return value
+}
@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ID of the subnet of subnetJSON
+const subnetID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-snet/providers/Microsoft.Network/virtualNetworks/vnet/subnets/snet"
+
+// Starts a fake ARM serving the subnet of subnetJSON, failing the first requests for it with the given status codes
+func newFakeARM(t *testing.T, statusCodes ...int) *int32 {
+	requests := new(int32)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/providers/Microsoft.Network") {
+			w.Write([]byte(`{"resourceTypes": [{"resourceType": "virtualNetworks/subnets", "apiVersions": ["2023-05-01"]}]}`))
+			return
+		}
+		request := int(atomic.AddInt32(requests, 1))
+		w.Header().Set("x-ms-correlation-request-id", fmt.Sprintf("correlation-%d", request))
+		w.Header().Set("x-ms-request-id", fmt.Sprintf("request-%d", request))
+		if request <= len(statusCodes) {
+			if statusCodes[request-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			w.WriteHeader(statusCodes[request-1])
+			w.Write([]byte(fmt.Sprintf(`{"error": {"code": "Status%d", "message": "injected failure"}}`, statusCodes[request-1])))
+			return
+		}
+		w.Write([]byte(subnetJSON))
+	}))
+
+	setAzureCloudEnv(t, "", server.URL)
+	SetCredentialProvider(StaticTokenCredentialProvider{})
+	armTransport = server.Client()
+	options := DefaultRetryOptions
+	DefaultRetryOptions = RetryOptions{Timeout: 10 * time.Second, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, WaitForExistence: true}
+	t.Cleanup(func() {
+		server.Close()
+		SetCredentialProvider(nil)
+		armTransport = nil
+		DefaultRetryOptions = options
+		apiVersions.Delete("microsoft.network/virtualnetworks/subnets")
+	})
+	return requests
+}
+
+func TestGetAzureResourceERetries(t *testing.T) {
+	requests := newFakeARM(t, http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusNotFound)
+
+	start := time.Now()
+	resource, err := GetAzureResourceE(subnetID)
+	require.NoError(t, err)
+	AssertProperty(t, resource, "name", "snet")
+	assert.Equal(t, int32(4), atomic.LoadInt32(requests))
+	// The 429 asked to wait for 1 second
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestGetAzureResourceEError(t *testing.T) {
+	requests := newFakeARM(t, http.StatusForbidden)
+
+	_, err := GetAzureResourceE(subnetID)
+	armErr := &ARMError{}
+	require.True(t, errors.As(err, &armErr))
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	assert.Equal(t, http.StatusForbidden, armErr.StatusCode)
+	assert.Equal(t, "Status403", armErr.ErrorCode)
+	assert.Contains(t, err.Error(), fmt.Sprintf("unable to get %s after 1 attempt(s) (status 403, code Status403, correlation ID correlation-1, request ID request-1)", subnetID))
+}
+
+func TestRetryARMETimeout(t *testing.T) {
+	attempts := 0
+	_, err := RetryARME(RetryOptions{Timeout: 100 * time.Millisecond, BaseDelay: 20 * time.Millisecond, MaxDelay: 20 * time.Millisecond}, "subnets of vnet",
+		func() (map[string]string, error) {
+			attempts++
+			header := http.Header{"X-Ms-Correlation-Request-Id": []string{"correlation"}}
+			return nil, autorest.NewErrorWithResponse("network.SubnetsClient", "List", &http.Response{StatusCode: http.StatusInternalServerError, Header: header}, "Failure")
+		})
+	assert.GreaterOrEqual(t, attempts, 3)
+	assert.ErrorContains(t, err, fmt.Sprintf("unable to get subnets of vnet after %d attempt(s) (status 500, correlation ID correlation)", attempts))
+
+	// Not found is only retried with WaitForExistence
+	attempts = 0
+	_, err = RetryARME(RetryOptions{Timeout: time.Second}, "subnets of vnet", func() (map[string]string, error) {
+		attempts++
+		return nil, autorest.NewErrorWithResponse("network.SubnetsClient", "List", &http.Response{StatusCode: http.StatusNotFound}, "Failure")
+	})
+	assert.Equal(t, 1, attempts)
+	assert.ErrorContains(t, err, "status 404")
+}
+
+func TestRetryARMENonPositiveDelay(t *testing.T) {
+	attempts := 0
+	start := time.Now()
+	_, err := RetryARME(RetryOptions{Timeout: 1500 * time.Millisecond}, "subnets of vnet", func() (map[string]string, error) {
+		attempts++
+		return nil, autorest.NewErrorWithResponse("network.SubnetsClient", "List", &http.Response{StatusCode: http.StatusTooManyRequests}, "Failure")
+	})
+	// Retried after defaultRetryBaseDelay, then given up as the doubled delay passes the timeout
+	assert.Equal(t, 2, attempts)
+	assert.GreaterOrEqual(t, time.Since(start), defaultRetryBaseDelay)
+	assert.ErrorContains(t, err, "status 429")
+}
@@ -0,0 +1,47 @@
+package helpers
+
+// Expected route table. Zero valued fields are not compared. The routes are compared by name, reporting every missing
+// and extra route.
+type RouteTableExpectation struct {
+	Name string
+	// Compared regardless of case and spaces (eg. CanadaCentral matches canadacentral)
+	Location string
+	// Compared when set (false is compared too)
+	DisableBGPRoutePropagation *bool
+	// Every route of the route table (an empty list expects no routes, nil does not compare them)
+	Routes []RouteExpectation
+}
+
+// Expected route of a route table. Zero valued fields are not compared.
+type RouteExpectation struct {
+	Name string
+	// CIDR or service tag (eg. AzureCloud)
+	AddressPrefix string
+	// VirtualNetworkGateway, VnetLocal, Internet, VirtualAppliance or None (compared regardless of case)
+	NextHopType string
+	// Only set on the routes to a VirtualAppliance
+	NextHopIPAddress string
+}
+
+func (e RouteTableExpectation) Diff(resource *AzureResource) []Mismatch {
+	mismatches := diffFields(resource, []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"Location", "location", e.Location, equalLocation},
+		{"DisableBGPRoutePropagation", "properties.disableBgpRoutePropagation", boolValue(e.DisableBGPRoutePropagation), equalValues},
+	})
+	if e.Routes == nil {
+		return mismatches
+	}
+	return append(mismatches, diffNamedItems(resource, "Routes", "properties.routes", e.Routes,
+		func(route RouteExpectation) string { return route.Name })...)
+}
+
+// Compares a route, either fetched on its own or taken from the routes of its route table
+func (e RouteExpectation) Diff(resource *AzureResource) []Mismatch {
+	return diffFields(resource, []fieldCheck{
+		{"Name", "name", e.Name, equalValues},
+		{"AddressPrefix", "properties.addressPrefix", e.AddressPrefix, equalValues},
+		{"NextHopType", "properties.nextHopType", e.NextHopType, equalFold},
+		{"NextHopIPAddress", "properties.nextHopIpAddress", e.NextHopIPAddress, equalValues},
+	})
+}
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const routeTableJSON = `{
+  "name": "route-table",
+  "location": "canadacentral",
+  "properties": {
+    "disableBgpRoutePropagation": false,
+    "routes": [
+      {"name": "default", "properties": {"addressPrefix": "0.0.0.0/0", "nextHopType": "VirtualAppliance", "nextHopIpAddress": "10.0.1.4"}},
+      {"name": "blackhole", "properties": {"addressPrefix": "192.168.0.0/16", "nextHopType": "None"}}
+    ]
+  }
+}`
+
+func TestRouteTableExpectationDiff(t *testing.T) {
+	resource := newTestResource(t, routeTableJSON)
+	disabled, enabled := true, false
+
+	assert.Empty(t, RouteTableExpectation{
+		Name:                       "route-table",
+		Location:                   "Canada Central",
+		DisableBGPRoutePropagation: &enabled,
+		Routes: []RouteExpectation{
+			{Name: "blackhole", AddressPrefix: "192.168.0.0/16", NextHopType: "none"},
+			{Name: "default", AddressPrefix: "0.0.0.0/0", NextHopType: "VirtualAppliance", NextHopIPAddress: "10.0.1.4"},
+		},
+	}.Diff(resource))
+
+	// Routes are not compared unless set
+	assert.Empty(t, RouteTableExpectation{Name: "route-table"}.Diff(resource))
+
+	mismatches := RouteTableExpectation{
+		DisableBGPRoutePropagation: &disabled,
+		Routes: []RouteExpectation{
+			{Name: "default", NextHopIPAddress: "10.0.1.5"},
+			{Name: "internet", AddressPrefix: "0.0.0.0/0", NextHopType: "Internet"},
+		},
+	}.Diff(resource)
+	lines := []string{}
+	for _, mismatch := range mismatches {
+		lines = append(lines, mismatch.String())
+	}
+	assert.Equal(t, []string{
+		"DisableBGPRoutePropagation (properties.disableBgpRoutePropagation): expected true, actual false",
+		`Routes[default].NextHopIPAddress (properties.routes[0].properties.nextHopIpAddress): expected "10.0.1.5", actual "10.0.1.4"`,
+		`Routes (properties.routes[*].name): expected "internet", actual <none>`,
+		`Routes (properties.routes[*].name): expected <none>, actual "blackhole"`,
+	}, lines)
+}
@@ -0,0 +1,202 @@
+package helpers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+// A module of a stack deployed by DeployStack. The module is copied to <testRootDir><Name>/ and its options are saved
+// in <Name>TerraformOptions/ (see StackTerraformOptionsDir).
+type StackModule struct {
+	// Unique name of the module in the stack (eg. vnet)
+	Name string
+	// Folder of the module (eg. ../../terraform-azurerm-vnet)
+	TerraformDir string
+	// Variables of the module. A StackOutput (at any depth of the maps and lists) is replaced by the output of a module
+	// deployed before this one.
+	Vars map[string]interface{}
+	// Policies the module plan is checked against before it is applied (see CheckPlanPolicies). The check is skipped if
+	// PolicyDir is empty.
+	PolicyDir     string
+	PolicyOptions PolicyOptions
+}
+
+// An output of a module deployed earlier in the stack, used as a variable of a later module (eg. the vnet_name output
+// of the vnet module as the vnet_name variable of the subnet module)
+type StackOutput struct {
+	// Name of the module in the stack
+	Module string
+	Output string
+	// Key of the value to use when the output is a map (eg. a subnet key of the subnet_ids output)
+	Key string
+}
+
+// Returns the directory the options of the stack module are saved in (eg. vnetTerraformOptions/)
+func StackTerraformOptionsDir(name string) string {
+	return name + "TerraformOptions/"
+}
+
+// Returns the folder the stack module is copied to (eg. <testRootDir>vnet/)
+func stackModuleDir(testRootDir string, name string) string {
+	return fmt.Sprintf("%s%s/", testRootDir, name)
+}
+
+// Copies each module of the stack into the testRootDir (see DeployStack) and lints the copy (see LintModule)
+func LintStack(t *testing.T, testRootDir string, modules []StackModule) {
+	t.Helper()
+	for _, module := range modules {
+		moduleDir := stackModuleDir(testRootDir, module.Name)
+		CopyTerraformFolder(t, module.TerraformDir, moduleDir)
+		LintModule(t, moduleDir)
+	}
+}
+
+// Loads the options of the stack module saved by DeployStack
+func LoadStackTerraformOptions(t *testing.T, testRootDir string, name string) *terraform.Options {
+	return LoadTerraformOptions(t, testRootDir, StackTerraformOptionsDir(name))
+}
+
+// Deploys the modules of the stack in order into the testRootDir. The StackOutput variables of each module are
+// resolved from the outputs of the modules deployed before it, so a module can only refer to earlier modules.
+func DeployStack(t *testing.T, testRootDir string, modules []StackModule) {
+	err := validateStack(modules)
+	if err != nil {
+		recordError(t, err.Error())
+	}
+	require.NoError(t, err)
+
+	outputs := map[string]map[string]interface{}{}
+	output := func(ref StackOutput) (interface{}, error) {
+		if _, ok := outputs[ref.Module]; !ok {
+			values, err := terraform.OutputAllE(t, LoadStackTerraformOptions(t, testRootDir, ref.Module))
+			if err != nil {
+				return nil, fmt.Errorf("unable to get the outputs of %s: %w", ref.Module, err)
+			}
+			outputs[ref.Module] = values
+		}
+		return stackOutputValue(outputs[ref.Module], ref)
+	}
+
+	for _, module := range modules {
+		vars, err := resolveStackOutputs(module.Vars, output)
+		if err != nil {
+			recordError(t, fmt.Sprintf("module %s of the stack: %v", module.Name, err))
+		}
+		require.NoError(t, err, "Module %s of the stack", module.Name)
+
+		moduleDir := stackModuleDir(testRootDir, module.Name)
+		CopyTerraformFolder(t, module.TerraformDir, moduleDir)
+		options := NewTerraformOptions(t, testRootDir, moduleDir, vars.(map[string]interface{}))
+		SaveTerraformOptions(t, testRootDir, StackTerraformOptionsDir(module.Name), options)
+
+		if module.PolicyDir != "" {
+			// Check the module plan against the security policies before applying it, in a stage of its own (eg.
+			// policy_vnet_TestNetworkStack/) so it can be skipped and reported per module
+			RunTestStage(t, "policy_"+module.Name+"_"+testRootDir, func() {
+				CheckPlanPolicies(t, options, module.PolicyDir, module.PolicyOptions)
+			})
+		}
+		InitAndApply(t, options)
+	}
+}
+
+// Destroys the modules of the stack in reverse order (so no module is destroyed before the ones depending on it), then
+// the infrastructure saved in each terraformOptionsDir (eg. the setup resources), and removes the testRootDir
+func TearDownStack(t *testing.T, testRootDir string, modules []StackModule, terraformOptionsDirs ...string) {
+	TearDown(t, testRootDir, append(stackTearDownOrder(modules), terraformOptionsDirs...)...)
+}
+
+// Returns the options directories of the stack modules in reverse order
+func stackTearDownOrder(modules []StackModule) []string {
+	dirs := []string{}
+	for i := len(modules) - 1; i >= 0; i-- {
+		dirs = append(dirs, StackTerraformOptionsDir(modules[i].Name))
+	}
+	return dirs
+}
+
+// Checks that the module names are unique and that every StackOutput refers to a module deployed before
+func validateStack(modules []StackModule) error {
+	deployed := map[string]bool{}
+	for _, module := range modules {
+		if module.Name == "" {
+			return fmt.Errorf("a module of the stack (%s) has no name", module.TerraformDir)
+		}
+		if deployed[module.Name] {
+			return fmt.Errorf("the stack has several modules named %s", module.Name)
+		}
+		_, err := resolveStackOutputs(module.Vars, func(ref StackOutput) (interface{}, error) {
+			if !deployed[ref.Module] {
+				return nil, fmt.Errorf("output %s of %s is not deployed before the module", ref.Output, ref.Module)
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return fmt.Errorf("module %s of the stack: %w", module.Name, err)
+		}
+		deployed[module.Name] = true
+	}
+	return nil
+}
+
+// Returns a copy of the value with every StackOutput replaced by the value returned by output
+func resolveStackOutputs(value interface{}, output func(StackOutput) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case StackOutput:
+		return output(v)
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := resolveStackOutputs(item, output)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := resolveStackOutputs(item, output)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	case []map[string]interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := resolveStackOutputs(item, output)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	}
+	return value, nil
+}
+
+// Returns the output (or the value at its key) from the outputs of a module (see terraform.OutputAllE)
+func stackOutputValue(outputs map[string]interface{}, ref StackOutput) (interface{}, error) {
+	value, ok := outputs[ref.Output]
+	if !ok {
+		return nil, fmt.Errorf("%s has no output %s", ref.Module, ref.Output)
+	}
+	if ref.Key == "" {
+		return value, nil
+	}
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("output %s of %s is not a map", ref.Output, ref.Module)
+	}
+	item, ok := values[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("output %s of %s has no key %s", ref.Output, ref.Module, ref.Key)
+	}
+	return item, nil
+}
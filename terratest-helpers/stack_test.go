@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveStackOutputs(t *testing.T) {
+	outputs := map[string]map[string]interface{}{
+		"vnet": {"vnet_name": "vnet-stack", "vnet_cidr": []interface{}{"10.0.0.0/16"}},
+		"nsg":  {"nsg_name": "nsg-stack", "security_rule_ids": map[string]interface{}{"allow-https": "rule-id"}},
+	}
+	output := func(ref StackOutput) (interface{}, error) {
+		return stackOutputValue(outputs[ref.Module], ref)
+	}
+
+	resolved, err := resolveStackOutputs(map[string]interface{}{
+		"vnet_name": StackOutput{Module: "vnet", Output: "vnet_name"},
+		"stack":     "stack",
+		"subnets": map[string]interface{}{
+			"app": map[string]interface{}{
+				"cidr_list":                   []string{"10.0.0.0/24"},
+				"network_security_group_name": StackOutput{Module: "nsg", Output: "nsg_name"},
+			},
+		},
+		"rules": []map[string]interface{}{{"id": StackOutput{Module: "nsg", Output: "security_rule_ids", Key: "allow-https"}}},
+	}, output)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"vnet_name": "vnet-stack",
+		"stack":     "stack",
+		"subnets": map[string]interface{}{
+			"app": map[string]interface{}{
+				"cidr_list":                   []string{"10.0.0.0/24"},
+				"network_security_group_name": "nsg-stack",
+			},
+		},
+		"rules": []interface{}{map[string]interface{}{"id": "rule-id"}},
+	}, resolved)
+
+	for _, ref := range []StackOutput{
+		{Module: "vnet", Output: "vnet_id"},
+		{Module: "vnet", Output: "vnet_name", Key: "app"},
+		{Module: "nsg", Output: "security_rule_ids", Key: "deny-all"},
+	} {
+		_, err := resolveStackOutputs(map[string]interface{}{"value": ref}, output)
+		assert.Error(t, err, fmt.Sprint(ref))
+	}
+}
+
+func TestValidateStack(t *testing.T) {
+	vnet := StackModule{Name: "vnet", TerraformDir: "../terraform-azurerm-vnet"}
+	subnet := StackModule{Name: "subnet", TerraformDir: "../terraform-azurerm-subnet", Vars: map[string]interface{}{
+		"vnet_name": StackOutput{Module: "vnet", Output: "vnet_name"},
+	}}
+
+	assert.NoError(t, validateStack([]StackModule{vnet, subnet}))
+	assert.EqualError(t, validateStack([]StackModule{subnet, vnet}), "module subnet of the stack: output vnet_name of vnet is not deployed before the module")
+	assert.EqualError(t, validateStack([]StackModule{vnet, vnet}), "the stack has several modules named vnet")
+	assert.Error(t, validateStack([]StackModule{{TerraformDir: "../terraform-azurerm-vnet"}}))
+}
+
+func TestStackTearDownOrder(t *testing.T) {
+	assert.Equal(t, []string{"subnetTerraformOptions/", "nsgTerraformOptions/", "vnetTerraformOptions/"},
+		stackTearDownOrder([]StackModule{{Name: "vnet"}, {Name: "nsg"}, {Name: "subnet"}}))
+}
+
+func TestLintStack(t *testing.T) {
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "variables.tf"), `variable "vnet_name" {
+  description = "Virtual network name"
+}
+`)
+	testRootDir := t.TempDir() + "/"
+
+	LintStack(t, testRootDir, []StackModule{{Name: "vnet", TerraformDir: src}})
+	// The copy of the module is linted
+	assert.FileExists(t, filepath.Join(testRootDir, "vnet", "variables.tf"))
+}
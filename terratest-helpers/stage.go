@@ -0,0 +1,249 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/shell"
+	ts "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+// Stage statuses
+const (
+	stagePassed  = "passed"
+	stageFailed  = "failed"
+	stageSkipped = "skipped"
+)
+
+// A test stage (eg. setup_TestSubnetWithDefaultConfigs/) run by RunTestStage
+type stageRecord struct {
+	// Full stage name (eg. setup_TestSubnetWithDefaultConfigs/)
+	Name string `json:"name"`
+	// Stage without the testRootDir (eg. setup)
+	Stage     string             `json:"stage"`
+	Status    string             `json:"status"`
+	Start     time.Time          `json:"start"`
+	End       time.Time          `json:"end"`
+	Duration  float64            `json:"durationSeconds"`
+	QueueWait float64            `json:"queueWaitSeconds"`
+	Terraform []terraformCommand `json:"terraform"`
+	Failures  []string           `json:"failures"`
+	// Failures reported by the helpers and the stage assertions (see Assert) during the stage. The stage fails if there
+	// are any, even if an earlier stage already failed the test.
+	errors []string
+	// Errors of the Terraform commands run during the stage, reported as its failures if the stage fails (a stage can
+	// tolerate them, eg. a destroy without state)
+	commandErrors []string
+	// Name of a nested stage (eg. policy_ in deploy_) that failed. Its failure is not also reported by this stage.
+	nestedFailure string
+	// testRootDir of the stage (eg. TestSubnetWithDefaultConfigs/)
+	testRootDir string
+}
+
+// A Terraform command run by the helpers during a stage
+type terraformCommand struct {
+	Command  string `json:"command"`
+	Dir      string `json:"dir"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// The stages run by a test
+type testRecord struct {
+	mutex sync.Mutex
+	name  string
+	start time.Time
+	// Stage currently running (nil between stages)
+	current *stageRecord
+	stages  []*stageRecord
+}
+
+// Records of the tests currently running, by test
+var testRecords sync.Map
+
+// Returns the record of the test, registering it (and the writing of its reports once it completes) on first use
+func getTestRecord(t *testing.T) *testRecord {
+	record, loaded := testRecords.LoadOrStore(t, &testRecord{name: t.Name(), start: time.Now()})
+	if !loaded {
+		t.Cleanup(func() {
+			testRecords.Delete(t)
+			if err := writeTestReports(record.(*testRecord)); err != nil {
+				t.Errorf("Unable to write the test reports: %v", err)
+			}
+		})
+	}
+	return record.(*testRecord)
+}
+
+// Returns the stage currently run by the test, or nil
+func currentStage(t *testing.T) *stageRecord {
+	record, ok := testRecords.Load(t)
+	if !ok {
+		return nil
+	}
+	r := record.(*testRecord)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.current
+}
+
+// Splits the stage name into the stage and its testRootDir (eg. setup and TestSubnetWithDefaultConfigs/). When the name
+// ends with a testRootDir of an earlier stage of the test, the rest is the stage, so a stage can be qualified (eg.
+// policy_vnet of policy_vnet_TestNetworkStack/ for the policy stage of the vnet module of a stack).
+func splitStageName(stageName string, testRootDirs []string) (string, string) {
+	for _, testRootDir := range testRootDirs {
+		if testRootDir != "" && strings.HasSuffix(stageName, "_"+testRootDir) {
+			return strings.TrimSuffix(stageName, "_"+testRootDir), testRootDir
+		}
+	}
+	parts := strings.SplitN(stageName, "_", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+// Runs the test stage unless SKIP_<stageName> is set, like ts.RunTestStage, recording its timings, status, failures and
+// the exit codes of the Terraform commands run through the helpers. A JUnit XML and a JSON report (with each stage as a
+// testcase) are written to the report directory once the test completes.
+func RunTestStage(t *testing.T, stageName string, stage func()) {
+	record := getTestRecord(t)
+	current := &stageRecord{Name: stageName, Start: time.Now()}
+
+	record.mutex.Lock()
+	testRootDirs := []string{}
+	for _, stage := range record.stages {
+		testRootDirs = append(testRootDirs, stage.testRootDir)
+	}
+	current.Stage, current.testRootDir = splitStageName(stageName, testRootDirs)
+	record.stages = append(record.stages, current)
+	previous := record.current
+	record.current = current
+	record.mutex.Unlock()
+
+	envVarName := ts.SKIP_STAGE_ENV_VAR_PREFIX + stageName
+	if os.Getenv(envVarName) != "" {
+		t.Logf("The '%s' environment variable is set, so skipping stage '%s'.", envVarName, stageName)
+		current.Status, current.End = stageSkipped, current.Start
+		record.mutex.Lock()
+		record.current = previous
+		record.mutex.Unlock()
+		return
+	}
+
+	resetStageLog(current.testRootDir, current.Stage)
+	t.Logf("Running stage '%s' (Terraform output in %s)", stageName, stageLogPath(current.testRootDir, current.Stage))
+
+	// Failures reported by earlier stages are not attributed to this one
+	failedBefore := t.Failed()
+	completed := false
+	// Deferred so the stage is also recorded when it calls t.FailNow (eg. through require) or panics
+	defer func() {
+		r := recover()
+		if r != nil {
+			current.errors = append(current.errors, fmt.Sprintf("panic: %v", r))
+		}
+
+		current.End = time.Now()
+		current.Duration = current.End.Sub(current.Start).Seconds()
+		current.Status = stageStatus(current, completed, t.Failed() && !failedBefore)
+		switch current.Status {
+		case stageFailed:
+			current.Failures = append(append([]string{}, current.errors...), current.commandErrors...)
+			if len(current.Failures) == 0 {
+				current.Failures = []string{"stage failed, see the test output"}
+			}
+			if tail, err := tailStageLog(current.testRootDir, current.Stage, logTailLines); err == nil && tail != "" {
+				t.Errorf("Stage '%s' failed after %.1fs, last lines of %s:\n%s", stageName, current.Duration,
+					stageLogPath(current.testRootDir, current.Stage), tail)
+			} else {
+				t.Errorf("Stage '%s' failed after %.1fs", stageName, current.Duration)
+			}
+			if previous != nil {
+				previous.nestedFailure = stageName
+			}
+		case stageSkipped:
+			t.Logf("Stage '%s' stopped after %.1fs by the failure of stage '%s'", stageName, current.Duration, current.nestedFailure)
+		default:
+			t.Logf("Stage '%s' passed in %.1fs", stageName, current.Duration)
+		}
+
+		record.mutex.Lock()
+		record.current = previous
+		record.mutex.Unlock()
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	stage()
+	completed = true
+}
+
+// Returns the status of a finished stage. A stage fails if it recorded failures, or if it did not complete or failed the
+// test (testFailed, a test failed by an earlier stage is not attributed to this one) without a nested stage failing. A
+// stage stopped by the failure of a nested stage (eg. a policy_ violation stopping deploy_ before the apply) is
+// reported as skipped, as the nested stage already reports the failure.
+func stageStatus(stage *stageRecord, completed bool, testFailed bool) string {
+	switch {
+	case len(stage.errors) > 0:
+		return stageFailed
+	case stage.nestedFailure != "" && !completed:
+		return stageSkipped
+	case stage.nestedFailure != "":
+		return stagePassed
+	case !completed || testFailed:
+		return stageFailed
+	}
+	return stagePassed
+}
+
+// Records a Terraform command run by the helpers (and its error) in the current stage of the test
+func recordTerraformCommand(t *testing.T, command string, dir string, err error) {
+	stage := currentStage(t)
+	if stage == nil {
+		return
+	}
+	exitCode := 0
+	if err != nil {
+		exitCode = 1
+		if code, codeErr := shell.GetExitCodeForRunCommandError(err); codeErr == nil && code != 0 {
+			exitCode = code
+		}
+		stage.commandErrors = append(stage.commandErrors, fmt.Sprintf("terraform %s in %s: %v", command, dir, err))
+	}
+	stage.Terraform = append(stage.Terraform, terraformCommand{Command: command, Dir: dir, ExitCode: exitCode})
+}
+
+// Records an error reported by a helper (eg. a failed assertion) in the current stage of the test
+func recordError(t *testing.T, message string) {
+	if stage := currentStage(t); stage != nil {
+		stage.errors = append(stage.errors, message)
+	}
+}
+
+// Assertions recording their failures in the current stage of the test (see RunTestStage), so the stage is reported
+// failed even if an earlier stage already failed the test (eg. th.Assert(t).Len(routeIDs, 2))
+func Assert(t *testing.T) *assert.Assertions {
+	return assert.New(stageT{t})
+}
+
+// A testing.T recording the assertion failures in the current stage
+type stageT struct {
+	t *testing.T
+}
+
+func (s stageT) Errorf(format string, args ...interface{}) {
+	s.t.Helper()
+	recordError(s.t, strings.TrimSpace(fmt.Sprintf(format, args...)))
+	s.t.Errorf(format, args...)
+}
+
+func (s stageT) Helper() {
+	s.t.Helper()
+}
@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTestStage(t *testing.T) {
+	reportDir := t.TempDir()
+	t.Setenv(ReportDirEnv, reportDir)
+	t.Setenv("SKIP_validate_TestStages/", "true")
+
+	ran := []string{}
+	t.Run("TestStages", func(t *testing.T) {
+		RunTestStage(t, "setup_TestStages/", func() {
+			ran = append(ran, "setup")
+			recordTerraformCommand(t, "init", "TestStages/terraform/", nil)
+			recordTerraformCommand(t, "apply", "TestStages/terraform/", nil)
+		})
+		RunTestStage(t, "validate_TestStages/", func() {
+			ran = append(ran, "validate")
+		})
+		RunTestStage(t, "teardown_TestStages/", func() {
+			ran = append(ran, "teardown")
+			// Errors tolerated by a passing stage are not reported as failures
+			recordTerraformCommand(t, "destroy", "TestStages/terraform/", errors.New("no state"))
+		})
+	})
+	assert.Equal(t, []string{"setup", "teardown"}, ran)
+
+	contents, err := os.ReadFile(filepath.Join(reportDir, "TestRunTestStage_TestStages.json"))
+	require.NoError(t, err)
+	report := jsonTestReport{}
+	require.NoError(t, json.Unmarshal(contents, &report))
+	assert.Equal(t, "TestRunTestStage/TestStages", report.Test)
+	assert.Equal(t, stagePassed, report.Status)
+	require.Len(t, report.Stages, 3)
+	assert.Equal(t, "setup", report.Stages[0].Stage)
+	assert.Equal(t, stagePassed, report.Stages[0].Status)
+	assert.Equal(t, []terraformCommand{{"init", "TestStages/terraform/", 0}, {"apply", "TestStages/terraform/", 0}}, report.Stages[0].Terraform)
+	assert.Equal(t, stageSkipped, report.Stages[1].Status)
+	assert.Equal(t, stagePassed, report.Stages[2].Status)
+	assert.Empty(t, report.Stages[2].Failures)
+	assert.Equal(t, 1, report.Stages[2].Terraform[0].ExitCode)
+
+	contents, err = os.ReadFile(filepath.Join(reportDir, "TestRunTestStage_TestStages.xml"))
+	require.NoError(t, err)
+	suite := junitTestSuite{}
+	require.NoError(t, xml.Unmarshal(contents, &suite))
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Skipped)
+	assert.Equal(t, "validate_TestStages/", suite.TestCases[1].Name)
+	assert.NotNil(t, suite.TestCases[1].Skipped)
+}
+
+func TestStageStatus(t *testing.T) {
+	assert.Equal(t, stagePassed, stageStatus(&stageRecord{}, true, false))
+	// An assertion recorded by the stage fails it, even when the test was already failed by an earlier stage
+	assert.Equal(t, stageFailed, stageStatus(&stageRecord{errors: []string{"expected 2, actual 1"}}, true, false))
+	// A stage stopped by t.FailNow, or failing the test without recording it
+	assert.Equal(t, stageFailed, stageStatus(&stageRecord{}, false, false))
+	assert.Equal(t, stageFailed, stageStatus(&stageRecord{}, true, true))
+	// Terraform errors tolerated by a stage (eg. a destroy without state) do not fail it
+	assert.Equal(t, stagePassed, stageStatus(&stageRecord{commandErrors: []string{"terraform destroy: no state"}}, true, false))
+	// The failure of a nested stage is only reported by the nested stage
+	assert.Equal(t, stageSkipped, stageStatus(&stageRecord{nestedFailure: "policy_TestStages/"}, false, true))
+	assert.Equal(t, stagePassed, stageStatus(&stageRecord{nestedFailure: "policy_TestStages/"}, true, true))
+	assert.Equal(t, stageFailed, stageStatus(&stageRecord{nestedFailure: "policy_TestStages/", errors: []string{"failed"}}, true, true))
+}
+
+func TestSplitStageName(t *testing.T) {
+	stage, testRootDir := splitStageName("setup_TestStages/", nil)
+	assert.Equal(t, []string{"setup", "TestStages/"}, []string{stage, testRootDir})
+	// A stage qualified by a module of a stack, after the stages of its testRootDir
+	stage, testRootDir = splitStageName("policy_vnet_TestStages/", []string{"", "TestStages/"})
+	assert.Equal(t, []string{"policy_vnet", "TestStages/"}, []string{stage, testRootDir})
+	stage, testRootDir = splitStageName("cleanup", nil)
+	assert.Equal(t, []string{"cleanup", ""}, []string{stage, testRootDir})
+}
+
+func TestAssertRecordsInStage(t *testing.T) {
+	t.Setenv(ReportDirEnv, t.TempDir())
+	t.Run("TestStages", func(t *testing.T) {
+		RunTestStage(t, "validate_TestStages/", func() {
+			assert.True(t, Assert(t).Equal(1, 1))
+			assert.Empty(t, currentStage(t).errors)
+		})
+	})
+}
+
+func TestWriteTestReportsFailedStage(t *testing.T) {
+	reportDir := t.TempDir()
+	t.Setenv(ReportDirEnv, reportDir)
+
+	start := time.Now()
+	record := &testRecord{name: "TestFailed", start: start, stages: []*stageRecord{
+		{Name: "deploy_TestFailed/", Stage: "deploy", Status: stageFailed, Start: start, End: start,
+			Terraform: []terraformCommand{{"apply", "TestFailed/module/", 1}},
+			Failures:  []string{"terraform apply in TestFailed/module/: exit status 1"}},
+	}}
+	require.NoError(t, writeTestReports(record))
+
+	contents, err := os.ReadFile(filepath.Join(reportDir, "TestFailed.xml"))
+	require.NoError(t, err)
+	suite := junitTestSuite{}
+	require.NoError(t, xml.Unmarshal(contents, &suite))
+	assert.Equal(t, 1, suite.Failures)
+	require.NotNil(t, suite.TestCases[0].Failure)
+	assert.Equal(t, "terraform apply in TestFailed/module/: exit status 1", suite.TestCases[0].Failure.Message)
+	assert.Equal(t, "terraform apply in TestFailed/module/: exit code 1", suite.TestCases[0].SystemOut.Text)
+}
@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+// Environment variables used to select the Terraform binary. Like the SKIP_ variables, each one can be suffixed with
+// the testRootDir (eg. TERRATEST_TERRAFORM_VERSION_TestSubnetWithDefaultConfigs/) to only apply to that test.
+const (
+	// Name or path of the binary (eg. terraform, tofu or /opt/tofu/bin/tofu). Defaults to terraform.
+	TerraformBinaryEnv = "TERRATEST_TERRAFORM_BINARY"
+	// Version of the binary to resolve from the cache directory (eg. 1.3.2)
+	TerraformVersionEnv = "TERRATEST_TERRAFORM_VERSION"
+	// Directory containing the downloaded binaries. Defaults to <user cache dir>/terratest/bin.
+	TerraformCacheDirEnv = "TERRATEST_TERRAFORM_CACHE_DIR"
+)
+
+const defaultTerraformBinary = "terraform"
+
+// Creates the terraform.Options used by a test stage: the Terraform output goes to the stage logs, and the same binary,
+// credentials, cloud and provider sources are used by every stage of the test
+func NewTerraformOptions(t *testing.T, testRootDir string, terraformDir string, vars map[string]interface{}) *terraform.Options {
+	options := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformBinary: GetTerraformBinary(t, testRootDir),
+		TerraformDir:    terraformDir,
+		Vars:            vars,
+		Logger:          NewStageLogger(testRootDir),
+	})
+	configureCredentials(t, options)
+	configureSensitiveVars(t, options)
+	configureAzureCloud(t, options)
+	configurePluginCache(t, options)
+	configureProviderMirror(t, testRootDir, options)
+	return options
+}
+
+// Resolves the Terraform binary for the test, failing the test if it cannot be found. The setup and module
+// configurations are then always run (and later destroyed) by the same Terraform or OpenTofu version.
+func GetTerraformBinary(t *testing.T, testRootDir string) string {
+	binary, err := GetTerraformBinaryE(testRootDir)
+	require.NoError(t, err)
+	return binary
+}
+
+// Resolves the Terraform binary for the test. If a version is set the binary is looked up in the cache directory using
+// either the <cache>/<version>/<binary> or <cache>/<binary>_<version> layout, otherwise the binary is looked up on the
+// PATH (or used as is when it is a path).
+func GetTerraformBinaryE(testRootDir string) (string, error) {
+	binary := getTestEnv(TerraformBinaryEnv, testRootDir)
+	if binary == "" {
+		binary = defaultTerraformBinary
+	}
+
+	version := getTestEnv(TerraformVersionEnv, testRootDir)
+	if version == "" {
+		path, err := exec.LookPath(binary)
+		if err != nil {
+			return "", fmt.Errorf("terraform binary %q not found: %w", binary, err)
+		}
+		return path, nil
+	}
+
+	cacheDir := getTestEnv(TerraformCacheDirEnv, testRootDir)
+	if cacheDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("%s is not set and the user cache directory is unknown: %w", TerraformCacheDirEnv, err)
+		}
+		cacheDir = filepath.Join(userCacheDir, "terratest", "bin")
+	}
+
+	name := filepath.Base(binary)
+	candidates := []string{
+		filepath.Join(cacheDir, version, name),
+		filepath.Join(cacheDir, fmt.Sprintf("%s_%s", name, strings.TrimPrefix(version, "v"))),
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s %s not found in %s (looked for %s)", name, version, cacheDir, strings.Join(candidates, ", "))
+}
+
+// Returns the value of the test specific environment variable (<key>_<testRootDir>), falling back to <key>
+func getTestEnv(key string, testRootDir string) string {
+	if value := os.Getenv(key + "_" + testRootDir); value != "" {
+		return value
+	}
+	return os.Getenv(key)
+}
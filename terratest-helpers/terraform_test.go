@@ -0,0 +1,56 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeExecutable(t *testing.T, path string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0755))
+}
+
+func TestGetTerraformBinaryFromCacheDir(t *testing.T) {
+	cacheDir := t.TempDir()
+	writeExecutable(t, filepath.Join(cacheDir, "1.3.2", "terraform"))
+	writeExecutable(t, filepath.Join(cacheDir, "tofu_1.6.0"))
+
+	t.Setenv(TerraformCacheDirEnv, cacheDir)
+	t.Setenv(TerraformVersionEnv, "1.3.2")
+
+	binary, err := GetTerraformBinaryE("TestBinary/")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(cacheDir, "1.3.2", "terraform"), binary)
+
+	// Test specific variables override the global ones
+	t.Setenv(TerraformBinaryEnv+"_TestTofu/", "tofu")
+	t.Setenv(TerraformVersionEnv+"_TestTofu/", "v1.6.0")
+
+	binary, err = GetTerraformBinaryE("TestTofu/")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(cacheDir, "tofu_1.6.0"), binary)
+}
+
+func TestGetTerraformBinaryMissingVersion(t *testing.T) {
+	t.Setenv(TerraformCacheDirEnv, t.TempDir())
+	t.Setenv(TerraformVersionEnv, "0.0.1")
+
+	_, err := GetTerraformBinaryE("TestBinary/")
+	assert.ErrorContains(t, err, "terraform 0.0.1 not found")
+}
+
+func TestGetTerraformBinaryFromPath(t *testing.T) {
+	binDir := t.TempDir()
+	writeExecutable(t, filepath.Join(binDir, "tofu"))
+
+	t.Setenv("PATH", binDir)
+	t.Setenv(TerraformBinaryEnv, "tofu")
+
+	binary, err := GetTerraformBinaryE("TestBinary/")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(binDir, "tofu"), binary)
+}